@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// compileCache remembers the output of recently compiled scripts, so that
+// scripts which haven't changed don't need to be run again.  Entries are
+// keyed by the script's source code, data directory and random seed, and are
+// only used if none of the data files loaded by the script have changed
+// since.
+type compileCache struct {
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[[sha256.Size]byte]cacheEntry
+	// order lists the keys of entries from oldest to newest.
+	order [][sha256.Size]byte
+}
+
+type cacheEntry struct {
+	output    string
+	dataFiles map[string][sha256.Size]byte
+}
+
+func newCompileCache(maxEntries int) *compileCache {
+	return &compileCache{
+		maxEntries: maxEntries,
+		entries:    map[[sha256.Size]byte]cacheEntry{},
+	}
+}
+
+func cacheKey(jsInput string, opts compileOptions) [sha256.Size]byte {
+	return sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%t\x00%v\x00%t\x00%t\x00%s\x00%t\x00%d\x00%q\x00%#v\x00%#v\x00%#v\x00%s",
+		opts.BaseDir, opts.Seed, opts.Center, opts.Fit, opts.Validate, opts.UnionAll, opts.Backend, opts.Compact,
+		opts.Precision, opts.Indent, opts.Defines, opts.Plugins, opts.Init, jsInput)))
+}
+
+// lookup returns the cached output of the given script, if it is available
+// and still up to date.
+func (c *compileCache) lookup(jsInput string, opts compileOptions) (string, bool) {
+	c.mutex.Lock()
+	entry, ok := c.entries[cacheKey(jsInput, opts)]
+	c.mutex.Unlock()
+	if !ok {
+		return "", false
+	}
+	if dataFilesChanged(entry.dataFiles) {
+		return "", false
+	}
+	return entry.output, true
+}
+
+// dataFilesChanged returns true if any of the given files can no longer be
+// read or no longer have the given SHA-256 hashes.
+func dataFilesChanged(dataFiles map[string][sha256.Size]byte) bool {
+	for path, hash := range dataFiles {
+		data, err := ioutil.ReadFile(path)
+		if err != nil || sha256.Sum256(data) != hash {
+			return true
+		}
+	}
+	return false
+}
+
+// compile runs the given script like jsToScad, and adds its output to the
+// cache if it succeeds.  opts.DataFiles is filled in as for compile if it is
+// not nil.
+func (c *compileCache) compile(jsInput string, opts compileOptions) (string, error) {
+	var output bytes.Buffer
+	if opts.DataFiles == nil {
+		opts.DataFiles = map[string][sha256.Size]byte{}
+	}
+	if err := compile(&output, jsInput, opts); err != nil {
+		return "", err
+	}
+
+	key := cacheKey(jsInput, opts)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = cacheEntry{
+		output:    output.String(),
+		dataFiles: opts.DataFiles,
+	}
+	for len(c.order) > c.maxEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	return output.String(), nil
+}
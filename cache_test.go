@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-scad-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dataFile := filepath.Join(dir, "size.json")
+	if err := ioutil.WriteFile(dataFile, []byte(`{"size": 5}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := "pendown(); forward(load_json('size.json').size); penup();"
+	opts := compileOptions{BaseDir: dir}
+	cache := newCompileCache(2)
+
+	if _, ok := cache.lookup(script, opts); ok {
+		t.Fatal("empty cache returned an entry")
+	}
+	first, err := cache.compile(script, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, ok := cache.lookup(script, opts); !ok || output != first {
+		t.Errorf("lookup after compile returned %q, %t", output, ok)
+	}
+	expected, err := jsToScad(script, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != expected {
+		t.Errorf("cached output differs from jsToScad:\n%s\nexpected:\n%s", first, expected)
+	}
+
+	// Changing only the options misses, without losing the first entry
+	indented := opts
+	indented.Indent = "  "
+	if _, ok := cache.lookup(script, indented); ok {
+		t.Error("lookup with different options hit the cache")
+	}
+	second, err := cache.compile(script, indented)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Error("different options gave the same output")
+	}
+	if _, ok := cache.lookup(script, opts); !ok {
+		t.Error("first entry was lost")
+	}
+
+	// Changing a data file invalidates both entries
+	if err := ioutil.WriteFile(dataFile, []byte(`{"size": 7}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []compileOptions{opts, indented} {
+		if _, ok := cache.lookup(script, o); ok {
+			t.Errorf("lookup with indent %q hit the cache after the data changed", o.Indent)
+		}
+	}
+	third, err := cache.compile(script, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first {
+		t.Error("output didn't change with the data file")
+	}
+
+	// A third entry pushes out the oldest one
+	if _, err := cache.compile(script+"\n", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.lookup(script, opts); ok {
+		t.Error("oldest entry wasn't removed")
+	}
+	if _, ok := cache.lookup(script+"\n", opts); !ok {
+		t.Error("newest entry is missing")
+	}
+}
+
+func TestCompileCacheError(t *testing.T) {
+	cache := newCompileCache(1)
+	if _, err := cache.compile("nonexistent();", compileOptions{}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := cache.lookup("nonexistent();", compileOptions{}); ok {
+		t.Error("failed script was cached")
+	}
+}
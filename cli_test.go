@@ -0,0 +1,128 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// TestMain runs the go-scad command instead of the tests if runCommand
+// started this binary.
+func TestMain(m *testing.M) {
+	if os.Getenv("GO_SCAD_RUN_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// logTimestamp matches the date and time at the start of lines written by
+// the log package.
+var logTimestamp = regexp.MustCompile(`(?m)^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d `)
+
+// commandResult holds what the go-scad command printed and its exit status.
+type commandResult struct {
+	stdout, stderr string
+	status         int
+}
+
+// runCommand runs go-scad with the given arguments in dir, and returns what
+// it printed, with the timestamps removed from stderr.
+func runCommand(t *testing.T, dir string, stdin string, args ...string) commandResult {
+	t.Helper()
+	return runCommandWithEnv(t, dir, nil, stdin, args...)
+}
+
+// runCommandWithEnv is like runCommand, but adds the given NAME=VALUE
+// settings to the environment.
+func runCommandWithEnv(t *testing.T, dir string, env []string, stdin string, args ...string) commandResult {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GO_SCAD_RUN_MAIN=1"), env...)
+	cmd.Stdin = bytes.NewBufferString(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		t.Fatal(err)
+	}
+	return commandResult{
+		stdout: stdout.String(),
+		stderr: logTimestamp.ReplaceAllString(stderr.String(), ""),
+		status: cmd.ProcessState.ExitCode(),
+	}
+}
+
+// writeFiles creates a temporary directory holding the given files, and
+// returns its path.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "go-scad-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCompileFilesInParallel(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.js": "pendown(); forward(1); penup();",
+		"b.js": "pendown(); forward(2);\nnonexistent();",
+		"c.js": "pendown(); forward(0); forward(3); penup();",
+		"d.js": "pendown(); forward(4); penup();",
+	})
+	expected := "a.js: 1 polygons, 62 points, 1 segments, path length 1 mm, bounds [-0.5, -0.5] to [1.5, 0.5], compiled in X\n" +
+		"b.js: JavaScript error: ReferenceError: 'nonexistent' is not defined\n" +
+		"    at <anonymous>:2:1\n" +
+		"c.js: warning: line 1: Zero-length forward move\n" +
+		"c.js: 1 polygons, 64 points, 2 segments, path length 3 mm, bounds [-0.5, -0.5] to [3.5, 0.5], compiled in X\n" +
+		"d.js: 1 polygons, 62 points, 1 segments, path length 4 mm, bounds [-0.5, -0.5] to [4.5, 0.5], compiled in X\n"
+	compileTime := regexp.MustCompile(`compiled in \S+`)
+	// Run it several times, since the files finish in a different order
+	// each time
+	for i := 0; i < 5; i++ {
+		result := runCommand(t, dir, "", "--stats", "--strict", "a.js", "b.js", "c.js", "d.js")
+		if result.status != 1 {
+			t.Errorf("exit status %d, expected 1", result.status)
+		}
+		if result.stdout != "" {
+			t.Errorf("unexpected stdout %q", result.stdout)
+		}
+		stderr := compileTime.ReplaceAllString(result.stderr, "compiled in X")
+		if stderr != expected {
+			t.Fatalf("stderr:\n%s\nexpected:\n%s", stderr, expected)
+		}
+		for name, length := range map[string]string{"a": "1", "c": "3", "d": "4"} {
+			output, err := ioutil.ReadFile(filepath.Join(dir, name+".js.scad"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(output, []byte("["+length+",0.5]")) {
+				t.Errorf("%s.js.scad doesn't have the expected output:\n%s", name, output)
+			}
+		}
+	}
+}
@@ -0,0 +1,3140 @@
+package main
+
+import (
+	"github.com/nylen/go-scad/goscad"
+	"github.com/robertkrimen/otto"
+	"github.com/robertkrimen/otto/parser"
+
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// throwError aborts the running script with a JavaScript exception, which
+// is returned as an error by jsToScad unless the script catches it.
+func (s *script) throwError(message string) {
+	panic(s.vm.MakeCustomError("Error", message))
+}
+
+func (s *script) throwErrorf(format string, a ...interface{}) {
+	s.throwError(fmt.Sprintf(format, a...))
+}
+
+func (s *script) toJsValue(value interface{}) otto.Value {
+	jsValue, err := s.vm.ToValue(value)
+	if err != nil {
+		s.throwError(err.Error())
+	}
+	return jsValue
+}
+
+func (s *script) toFloat(value otto.Value) float64 {
+	if value.IsUndefined() {
+		s.throwError("Undefined value passed to toFloat()")
+	}
+	floatValue, err := value.ToFloat()
+	if err != nil {
+		s.throwError(err.Error())
+	}
+	return floatValue
+}
+
+func (s *script) toInt(value otto.Value) int {
+	if value.IsUndefined() {
+		s.throwError("Undefined value passed to toInt()")
+	}
+	int64Value, err := value.ToInteger()
+	if err != nil {
+		s.throwError(err.Error())
+	}
+	return int(int64Value)
+}
+
+func (s *script) toString(value otto.Value) string {
+	if value.IsUndefined() {
+		s.throwError("Undefined value passed to toString()")
+	}
+	stringValue, err := value.ToString()
+	if err != nil {
+		s.throwError(err.Error())
+	}
+	return stringValue
+}
+
+// toObjectMap returns the properties of a JavaScript object whose keys are
+// single characters.
+func (s *script) toObjectMap(value otto.Value) map[rune]otto.Value {
+	if !value.IsObject() {
+		s.throwError("Non-object value passed to toObjectMap()")
+	}
+	object := value.Object()
+	properties := map[rune]otto.Value{}
+	for _, key := range object.Keys() {
+		symbols := []rune(key)
+		if len(symbols) != 1 {
+			s.throwErrorf("Expected a single character key: %q", key)
+		}
+		properties[symbols[0]], _ = object.Get(key)
+	}
+	return properties
+}
+
+// getProperty returns a property of a JavaScript object such as an options
+// argument, or undefined if the object is undefined.
+func (s *script) getProperty(object otto.Value, key string) otto.Value {
+	if object.IsUndefined() {
+		return otto.UndefinedValue()
+	}
+	if !object.IsObject() {
+		s.throwError("Non-object value passed to getProperty()")
+	}
+	property, _ := object.Object().Get(key)
+	return property
+}
+
+// callFunction calls a JavaScript function passed to the script's library
+// functions.  If the function throws an exception, the script is aborted.
+func (s *script) callFunction(fn otto.Value, args ...interface{}) otto.Value {
+	if !fn.IsFunction() {
+		s.throwError("Non-function value passed to callFunction()")
+	}
+	result, err := fn.Call(otto.UndefinedValue(), args...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// toScadValue converts a JavaScript number, string, boolean or array of
+// these into an OpenSCAD literal.
+func (s *script) toScadValue(value otto.Value) string {
+	switch {
+	case value.Class() == "Array":
+		length := s.toInt(s.getProperty(value, "length"))
+		items := make([]string, length)
+		for i := range items {
+			items[i] = s.toScadValue(s.getProperty(value, strconv.Itoa(i)))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case value.IsNumber():
+		return s.formatFloat(s.toFloat(value))
+	case value.IsString():
+		return strconv.Quote(s.toString(value))
+	case value.IsBoolean():
+		return s.toString(value)
+	}
+	s.throwErrorf("Unsupported value: %s", value.String())
+	return ""
+}
+
+// formatFloat formats a number for the output with the precision given in
+// the script's options.
+func (s *script) formatFloat(n float64) string {
+	return goscad.FormatFloat(n, s.opts.Precision)
+}
+
+// toLength converts a length given by the script in its current units into
+// millimeters, which is the unit used by OpenSCAD.
+func (s *script) toLength(value otto.Value) float64 {
+	return s.toFloat(value) * s.unitScale
+}
+
+// fromLength converts a length in millimeters into the script's current
+// units.
+func (s *script) fromLength(mm float64) otto.Value {
+	return s.toJsValue(mm / s.unitScale)
+}
+
+// toPoint converts a JavaScript [x, y] pair into a point.
+func (s *script) toPoint(value otto.Value) [2]float64 {
+	if !value.IsObject() {
+		s.throwError("Non-array value passed to toPoint()")
+	}
+	length, _ := value.Object().Get("length")
+	if s.toInt(length) != 2 {
+		s.throwError("Invalid point: expected [x, y]")
+	}
+	x, _ := value.Object().Get("0")
+	y, _ := value.Object().Get("1")
+	return [2]float64{s.toFloat(x), s.toFloat(y)}
+}
+
+// toPoints converts a JavaScript array of [x, y] pairs into a list of
+// points.
+func (s *script) toPoints(value otto.Value) [][2]float64 {
+	if !value.IsObject() {
+		s.throwError("Non-array value passed to toPoints()")
+	}
+	length, _ := value.Object().Get("length")
+	if length.IsUndefined() {
+		s.throwError("Non-array value passed to toPoints()")
+	}
+	points := make([][2]float64, s.toInt(length))
+	for i := range points {
+		pair, _ := value.Object().Get(strconv.Itoa(i))
+		if !pair.IsObject() {
+			s.throwErrorf("Invalid point at index %d: expected [x, y]", i)
+		}
+		pairLength, _ := pair.Object().Get("length")
+		if pairLength.IsUndefined() || s.toInt(pairLength) != 2 {
+			s.throwErrorf("Invalid point at index %d: expected [x, y]", i)
+		}
+		x, _ := pair.Object().Get("0")
+		y, _ := pair.Object().Get("1")
+		points[i] = [2]float64{s.toFloat(x), s.toFloat(y)}
+	}
+	return points
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func degCos(deg float64) float64 {
+	return math.Cos(degToRad(deg))
+}
+
+func degSin(deg float64) float64 {
+	return math.Sin(degToRad(deg))
+}
+
+type TurtlePoint = goscad.Point
+
+type TurtlePolygon struct {
+	Points    []TurtlePoint
+	Headings  []float64
+	ZeroWidth bool
+	// Resumed is true if the polygon was started automatically when the
+	// turtle jumped with the pen down, in which case it is not drawn as a
+	// dot if nothing else is drawn.
+	Resumed bool
+	// If Symmetric is true, the polygon is also drawn mirrored across a line
+	// through the origin at an angle of SymmetryAxis degrees.
+	Symmetric    bool
+	SymmetryAxis float64
+	// If HollowWall is non-zero, only a wall of this thickness around the
+	// inside edge of the polygon is drawn.
+	HollowWall float64
+	// If FilletRadius is non-zero, the polygon's corners are rounded so
+	// that the inside edge of each corner has this radius.
+	FilletRadius float64
+	// If ChamferSetback is non-zero, the polygon's corners are cut off this
+	// far from each corner.
+	ChamferSetback float64
+	// If Nib is not nil, the polygon is drawn by sweeping a pen of this
+	// convex shape (given as offsets from the turtle's position) along it,
+	// instead of a round pen.
+	Nib [][2]float64
+	// If PenWidth is a function, it gives half the thickness of the line at
+	// each distance along it (as a fraction of its length if
+	// PenWidthNormalized is true).  The line is split into PenWidthSamples
+	// pieces first, so that its thickness changes smoothly.
+	PenWidth           otto.Value
+	PenWidthNormalized bool
+	PenWidthSamples    int
+	// If SmoothSamples is non-zero, the polygon is drawn as a smooth curve
+	// through its points, with this many samples between each pair of
+	// points.
+	SmoothSamples int
+	// If Tube is true, the line is drawn in 3D as a tube with a round
+	// cross-section instead of as a polygon.
+	Tube bool
+	// Line is the line of the script where the polygon was started, if
+	// output validation is enabled.
+	Line int
+}
+
+// identifierPattern matches valid OpenSCAD identifiers.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// specialVariablePattern matches the names of OpenSCAD's special variables,
+// such as $fn.
+var specialVariablePattern = regexp.MustCompile(`^\$[A-Za-z_][A-Za-z0-9_]*$`)
+
+// outputBackends lists the ways that lines can be written to the output:
+// as polygons of their outlines, or as calls to the BOSL2 library's stroke()
+// module.
+var outputBackends = []string{"polygon", "bosl2"}
+
+// unitScales gives the size of each unit supported by units() in
+// millimeters.
+var unitScales = map[string]float64{
+	"mm": 1,
+	"cm": 10,
+	"in": 25.4,
+}
+
+func formatFloat(n float64) string {
+	return goscad.FormatFloat(n, 6)
+}
+
+// compileOptions controls how jsToScad runs a script.
+type compileOptions struct {
+	// BaseDir is the directory that data files are loaded from.  If empty,
+	// the script may not load data files.
+	BaseDir string
+	// Timeout, if non-zero, limits how long the script may run.
+	Timeout time.Duration
+	// Seed is the initial seed for random numbers generated by the script.
+	Seed int64
+	// Warn, if set, enables checks for degenerate geometry and is called
+	// with each problem found.
+	Warn func(warning diagnostic)
+	// Center moves the drawing so that its bounding box is centered at the
+	// origin.
+	Center bool
+	// Fit, if non-zero, is the width and height that the drawing is scaled
+	// to fit into.  Unless Center is also set, its lower left corner is
+	// moved to the origin.
+	Fit [2]float64
+	// Stats, if not nil, receives information about the output.
+	Stats *compileStats
+	// DataFiles, if not nil, receives the path and SHA-256 hash of each data
+	// file loaded by the script, even if the script fails.
+	DataFiles map[string][sha256.Size]byte
+	// Validate enables checks on the polygons written to the output, which
+	// abort the script if a polygon is invalid.
+	Validate bool
+	// UnionAll wraps the whole drawing in a single union() block.
+	UnionAll bool
+	// Backend is one of outputBackends, or empty for "polygon".
+	Backend string
+	// Preview, if not nil, receives the outlines of the polygons in the
+	// output.
+	Preview *previewDrawing
+	// Compact leaves out indentation and the line breaks inside statements,
+	// to make the output smaller.
+	Compact bool
+	// Profile, if not nil, records where the time is spent compiling the
+	// script.
+	Profile *compileProfile
+	// Precision, if non-zero, is the largest number of digits written after
+	// the decimal point in numbers in the output (6 by default).
+	Precision int
+	// Indent, if not empty, is used to indent the output instead of tabs.
+	Indent string
+	// Defines gives the values of global variables which are set before
+	// the script runs.
+	Defines map[string]interface{}
+	// Plugins lists scripts which are run before the script itself, usually
+	// to register() extra commands for it.
+	Plugins []scriptFile
+	// Init lists scripts which are run after the plugins and before the
+	// script itself, usually to define constants and helper functions.
+	Init []scriptFile
+}
+
+// scriptFile is the source code of a script and the file name used for it
+// in error messages.
+type scriptFile struct {
+	Name   string
+	Source string
+}
+
+// errTimeout is returned by jsToScad when the script runs for longer than
+// the configured timeout.
+var errTimeout = errors.New("script timed out")
+
+// jsToScad runs the given go-scad script and returns the resulting OpenSCAD
+// code.
+func jsToScad(jsInput string, opts compileOptions) (string, error) {
+	var output strings.Builder
+	if err := compile(&output, jsInput, opts); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// compile runs the given go-scad script, then writes the resulting OpenSCAD
+// code to w.  Nothing is written if the script fails.  The body is kept in
+// the script's buffer until the script finishes, then written to w along
+// with the rest of the output without making another copy of it, so w may
+// receive part of it if writing fails.
+func compile(w io.Writer, jsInput string, opts compileOptions) error {
+	start := time.Now()
+	s := newScript(opts)
+	if opts.DataFiles != nil {
+		// Report the data files read before a failure too, since changing
+		// them may fix it
+		defer func() {
+			for path, hash := range s.dataFiles {
+				opts.DataFiles[path] = hash
+			}
+		}()
+	}
+	endScript := opts.Profile.stage("script")
+	if err := s.runPreludes(); err != nil {
+		return err
+	}
+	if _, err := s.run(jsInput); err != nil {
+		return err
+	}
+	endScript()
+	endOutput := opts.Profile.stage("output")
+	if err := s.writeOutput(w); err != nil {
+		return err
+	}
+	endOutput()
+	if opts.Preview != nil {
+		scale, offset := s.outputTransform()
+		for _, shape := range opts.Preview.Shapes {
+			for i := range shape {
+				for j := range offset {
+					shape[i][j] = shape[i][j]*scale + offset[j]
+				}
+			}
+		}
+	}
+	if opts.Stats != nil {
+		*opts.Stats = s.stats
+		opts.Stats.PathLength = s.pathLength
+		opts.Stats.Segments = s.segments
+		if !s.bounds.Empty {
+			scale, offset := s.outputTransform()
+			var bounds [2][2]float64
+			for i := range offset {
+				bounds[0][i] = s.bounds.Min[i]*scale + offset[i]
+				bounds[1][i] = s.bounds.Max[i]*scale + offset[i]
+			}
+			opts.Stats.Bounds = &bounds
+		}
+		opts.Stats.CompileTime = time.Since(start)
+	}
+	return nil
+}
+
+// compileStats describes the output of a script.  Lengths are in
+// millimeters.
+type compileStats struct {
+	// Polygons and Points count the polygon() statements in the output and
+	// the points in them.
+	Polygons int `json:"polygons"`
+	Points   int `json:"points"`
+	// Segments and PathLength count the lines drawn by the turtle and their
+	// total length.
+	Segments   int     `json:"segments"`
+	PathLength float64 `json:"path_length"`
+	// Bounds gives the lower left and upper right corners of the drawing,
+	// or nil if nothing was drawn.
+	Bounds      *[2][2]float64 `json:"bounds"`
+	CompileTime time.Duration  `json:"-"`
+}
+
+// turtleState holds the position and pen settings of the turtle.
+type turtleState struct {
+	Pendown     bool
+	PenSize     float64
+	EndCapSides int
+	// PenHeight is the height of the lines drawn in 3D by penheight(), or 0
+	// for flat lines.
+	PenHeight float64
+	X         float64
+	Y         float64
+	Heading   float64
+}
+
+// script holds the state of a go-scad program.  Each script has its own
+// JavaScript interpreter, so different scripts may run concurrently.
+type script struct {
+	vm   *otto.Otto
+	opts compileOptions
+	// out receives the generated OpenSCAD code.  This is usually body, but
+	// may be a module definition instead.
+	out         io.Writer
+	indentLevel int
+	// The generated OpenSCAD code is written in three parts: declarations
+	// of variables which belong at the top of the file, then the body, then
+	// the definitions of modules used in the body.
+	declarations []string
+	body         strings.Builder
+	modules      []*scadModule
+	// declared holds the names of variables which have been declared.
+	declared map[string]bool
+	// functions holds the OpenSCAD functions defined by deffunction(), which
+	// are written after the body, and functionNames holds their names.
+	functions     []string
+	functionNames map[string]bool
+	// anchors holds the named points defined by anchor(), which are
+	// written as variables after the body.
+	anchors []scadAnchor
+
+	turtle turtleState
+	// polygon is the line being drawn while the pen is down.
+	polygon TurtlePolygon
+	// savedStates holds the turtle states saved by push().
+	savedStates []turtleState
+	// If symmetric is true, lines are also drawn mirrored across a line
+	// through the origin at an angle of symmetryAxis degrees.
+	symmetric    bool
+	symmetryAxis float64
+	// hollowWall, if non-zero, is the thickness of the walls that lines are
+	// drawn with instead of being solid.
+	hollowWall float64
+	// filletRadius, if non-zero, is the radius that the inside corners of
+	// lines are rounded to.
+	filletRadius float64
+	// chamferSetback, if non-zero, is how far from each corner of a line
+	// the corner is cut off.
+	chamferSetback float64
+	// penShape is "round", "square" or "custom", and nibPoints is the shape
+	// of a custom pen.
+	penShape  string
+	nibPoints [][2]float64
+	// penWidth, if a function, gives the thickness along each line, as
+	// described for TurtlePolygon.
+	penWidth           otto.Value
+	penWidthNormalized bool
+	penWidthSamples    int
+	// smoothSamples, if non-zero, is the number of samples between the
+	// points of lines which are drawn as smooth curves.
+	smoothSamples int
+	// tube is true if lines are drawn in 3D as tubes.
+	tube bool
+	// recording, if not nil, receives the lines drawn inside record()
+	// instead of the output.
+	recording *recordedPath
+	// random generates the numbers returned by random() and Math.random().
+	random *rand.Rand
+	// units is the name of the units used for lengths in the script, and
+	// unitScale is the size of one of these units in millimeters.
+	units     string
+	unitScale float64
+	// snapGrid, if non-zero, is the grid size in millimeters that output
+	// coordinates are rounded to.
+	snapGrid float64
+	// outline collects the points of the polygon being written.
+	outline [][2]float64
+	// unionAll is true if the whole drawing is wrapped in a union() block.
+	unionAll bool
+	// backend is one of outputBackends, and usesBOSL2 is true if anything
+	// has been written which needs the BOSL2 library.
+	backend   string
+	usesBOSL2 bool
+	// bounds contains all of the polygons written so far, and boundsFormat
+	// is how it is written to the output ("comment" or "module"), if at all.
+	bounds       boundingBox
+	boundsFormat string
+	// stats counts the polygons in the output, and segments and pathLength
+	// count the lines drawn by the turtle.
+	stats      compileStats
+	segments   int
+	pathLength float64
+	// builtins lists the functions available to the program.
+	builtins []builtin
+	// dataFiles lists the data files loaded by the program and the SHA-256
+	// hashes of their contents.
+	dataFiles map[string][sha256.Size]byte
+}
+
+// scadAnchor is a named point where other parts can be attached to the
+// drawing, with a heading in degrees.
+type scadAnchor struct {
+	Name    string
+	X, Y    float64
+	Heading float64
+}
+
+// scadModule is an OpenSCAD module definition generated by a script.
+type scadModule struct {
+	Name string
+	Body strings.Builder
+}
+
+// newScript sets up a JavaScript interpreter with the go-scad library.
+func newScript(opts compileOptions) *script {
+	if opts.Precision == 0 {
+		opts.Precision = 6
+	}
+	if opts.Indent == "" {
+		opts.Indent = "\t"
+	}
+	if opts.Backend == "" {
+		opts.Backend = "polygon"
+	}
+	if opts.Compact {
+		opts.Indent = ""
+	}
+	s := &script{
+		opts: opts,
+		turtle: turtleState{
+			PenSize:     1,
+			EndCapSides: 60,
+		},
+		random:        rand.New(rand.NewSource(opts.Seed)),
+		units:         "mm",
+		unitScale:     1,
+		dataFiles:     map[string][sha256.Size]byte{},
+		declared:      map[string]bool{},
+		functionNames: map[string]bool{},
+		unionAll:      opts.UnionAll,
+		backend:       opts.Backend,
+		bounds:        newBoundingBox(),
+		penShape:      "round",
+	}
+	s.out = &s.body
+
+	write := func(strs ...string) {
+		for _, str := range strs {
+			if _, err := io.WriteString(s.out, str); err != nil {
+				s.throwError(err.Error())
+			}
+		}
+	}
+
+	indent := func(level int) string {
+		return strings.Repeat(s.opts.Indent, level)
+	}
+
+	// Starts a new line inside a statement, unless the output is compact,
+	// and separates the points in a list
+	lineBreak := func(level int) string {
+		if s.opts.Compact {
+			return ""
+		}
+		return "\n" + indent(level)
+	}
+	pointSeparator := " "
+	if opts.Compact {
+		pointSeparator = ""
+	}
+
+	// Records the outline of a shape in the output for the preview
+	addPreview := func(outline [][2]float64) {
+		if s.opts.Preview != nil {
+			s.opts.Preview.Shapes = append(s.opts.Preview.Shapes, outline)
+		}
+	}
+
+	// Notes a block whose effect on the code inside it isn't shown in the
+	// preview, since it is arbitrary OpenSCAD code
+	ignoreInPreview := func(block string) {
+		if s.opts.Preview == nil {
+			return
+		}
+		for _, ignored := range s.opts.Preview.Ignored {
+			if ignored == block {
+				return
+			}
+		}
+		s.opts.Preview.Ignored = append(s.opts.Preview.Ignored, block)
+	}
+
+	outBeginPolygon := func() {
+		s.stats.Polygons++
+		addPreview(nil)
+		write(indent(s.indentLevel), "polygon(points = [", lineBreak(s.indentLevel+1))
+	}
+
+	outNewLine := func() {
+		write(lineBreak(s.indentLevel + 1))
+	}
+
+	snapPoint := func(x float64, y float64) (float64, float64) {
+		if s.snapGrid > 0 {
+			x = math.Round(x/s.snapGrid) * s.snapGrid
+			y = math.Round(y/s.snapGrid) * s.snapGrid
+		}
+		return x, y
+	}
+
+	outPoint := func(x float64, y float64, isLast bool) {
+		space := pointSeparator
+		if isLast {
+			space = ""
+		}
+		x, y = snapPoint(x, y)
+		s.outline = append(s.outline, [2]float64{x, y})
+		if preview := s.opts.Preview; preview != nil {
+			last := len(preview.Shapes) - 1
+			preview.Shapes[last] = append(preview.Shapes[last], [2]float64{x, y})
+		}
+		s.stats.Points++
+		write("[", s.formatFloat(x), ",", s.formatFloat(y), "],", space)
+	}
+
+	outEndPolygon := func() {
+		write(lineBreak(s.indentLevel), "]);\n")
+	}
+
+	outBeginBlock := func(wrapper string) {
+		write(indent(s.indentLevel), wrapper, " {\n")
+		s.indentLevel += 1
+	}
+
+	outEndBlock := func() {
+		s.indentLevel -= 1
+		write(indent(s.indentLevel), "}\n")
+	}
+
+	outEcho := func(text string) {
+		lines := strings.Split(text, "\n")
+		for _, line := range lines {
+			write(indent(s.indentLevel), line, "\n")
+		}
+	}
+
+	// Writes the shape swept by a pen with a polygonal nib as the union of
+	// the convex hulls of the nib at the ends of each segment
+	writeNibPolygon := func(polygon TurtlePolygon) {
+		nibAt := func(points ...TurtlePoint) [][2]float64 {
+			var corners [][2]float64
+			for _, point := range points {
+				for _, offset := range polygon.Nib {
+					corners = append(corners, [2]float64{point.X + offset[0], point.Y + offset[1]})
+				}
+			}
+			return convexHull(corners)
+		}
+		endStrokes := s.opts.Profile.stage("strokes")
+		var hulls [][][2]float64
+		if len(polygon.Points) == 1 {
+			hulls = append(hulls, nibAt(polygon.Points[0]))
+		}
+		for i := 1; i < len(polygon.Points); i++ {
+			if hull := nibAt(polygon.Points[i-1], polygon.Points[i]); len(hull) >= 3 {
+				hulls = append(hulls, hull)
+			}
+		}
+		endStrokes()
+		defer s.opts.Profile.stage("formatting")()
+		if len(hulls) > 1 {
+			outBeginBlock("union()")
+		}
+		for _, hull := range hulls {
+			outBeginPolygon()
+			for i, point := range hull {
+				outPoint(point[0], point[1], i == len(hull)-1)
+			}
+			outEndPolygon()
+		}
+		if len(hulls) > 1 {
+			outEndBlock()
+		}
+	}
+
+	// Writes a line as a 3D tube, made of the convex hulls of spheres at the
+	// ends of each segment.  The spheres are made smaller by inset all
+	// around, to cut out the inside of a hollow tube.  Only the outside of
+	// the tube is added to the outline, which is used for its bounds.
+	writeTube := func(polygon TurtlePolygon, inset float64) {
+		defer s.opts.Profile.stage("formatting")()
+		if inset == 0 {
+			s.outline = s.outline[:0]
+		}
+		sphere := func(point TurtlePoint) {
+			x, y := snapPoint(point.X, point.Y)
+			diameter := point.Thickness - inset*2
+			if diameter <= 0 {
+				s.throwError("Hollow wall is too thick for the tube")
+			}
+			if inset == 0 {
+				r := diameter / 2
+				s.outline = append(s.outline, [2]float64{x - r, y}, [2]float64{x, y + r},
+					[2]float64{x + r, y}, [2]float64{x, y - r})
+			}
+			write(indent(s.indentLevel), "translate([", s.formatFloat(x), ",", s.formatFloat(y),
+				"]) sphere(d = ", s.formatFloat(diameter), ", $fn = ", strconv.Itoa(point.EndCapSides), ");\n")
+		}
+		if len(polygon.Points) == 1 {
+			sphere(polygon.Points[0])
+			return
+		}
+		if len(polygon.Points) > 2 {
+			outBeginBlock("union()")
+		}
+		for i := 1; i < len(polygon.Points); i++ {
+			outBeginBlock("hull()")
+			sphere(polygon.Points[i-1])
+			sphere(polygon.Points[i])
+			outEndBlock()
+		}
+		if len(polygon.Points) > 2 {
+			outEndBlock()
+		}
+	}
+
+	// Writes a polyhedron whose bottom and top faces have the given points,
+	// which go clockwise when seen from above, and whose sides join each
+	// pair of points on the bottom and top
+	outPolyhedron := func(bottom, top [][3]float64) {
+		n := len(bottom)
+		formatPoints := func(points [][3]float64) string {
+			formatted := make([]string, n)
+			for i, point := range points {
+				x, y := snapPoint(point[0], point[1])
+				s.outline = append(s.outline, [2]float64{x, y})
+				formatted[i] = "[" + s.formatFloat(x) + "," + s.formatFloat(y) + "," + s.formatFloat(point[2]) + "],"
+			}
+			return strings.Join(formatted, pointSeparator)
+		}
+		bottomFace := make([]string, n)
+		topFace := make([]string, n)
+		sides := make([]string, n)
+		for i := range bottom {
+			bottomFace[i] = strconv.Itoa(n - 1 - i)
+			topFace[i] = strconv.Itoa(n + i)
+			j := (i + 1) % n
+			sides[i] = fmt.Sprintf("[%d,%d,%d,%d],", i, j, n+j, n+i)
+		}
+		write(indent(s.indentLevel), "polyhedron(points = [",
+			lineBreak(s.indentLevel+1), formatPoints(bottom),
+			lineBreak(s.indentLevel+1), formatPoints(top),
+			lineBreak(s.indentLevel), "], faces = [",
+			lineBreak(s.indentLevel+1), "[", strings.Join(bottomFace, ","), "],",
+			lineBreak(s.indentLevel+1), "[", strings.Join(topFace, ","), "],",
+			lineBreak(s.indentLevel+1), strings.Join(sides, pointSeparator),
+			lineBreak(s.indentLevel), "]);\n")
+	}
+
+	// Writes a line with a height as a 3D solid for each of its segments:
+	// an extruded outline if both ends of the segment have the same height,
+	// or a polyhedron which slopes between them otherwise
+	writeExtruded := func(polygon TurtlePolygon) {
+		s.outline = s.outline[:0]
+		type piece struct {
+			outline    [][2]float64
+			start, end TurtlePoint
+		}
+		endStrokes := s.opts.Profile.stage("strokes")
+		outlinePiece := func(points []TurtlePoint, headings []float64) piece {
+			var outline [][2]float64
+			for _, run := range goscad.Outline(points, headings) {
+				outline = append(outline, run...)
+			}
+			return piece{outline, points[0], points[len(points)-1]}
+		}
+		var pieces []piece
+		if len(polygon.Points) == 1 {
+			pieces = append(pieces, outlinePiece(polygon.Points, nil))
+		}
+		for i := 1; i < len(polygon.Points); i++ {
+			pieces = append(pieces, outlinePiece(polygon.Points[i-1:i+1], polygon.Headings[i-1:i]))
+		}
+		endStrokes()
+		defer s.opts.Profile.stage("formatting")()
+
+		if len(pieces) > 1 {
+			outBeginBlock("union()")
+		}
+		for _, piece := range pieces {
+			if piece.start.Height == piece.end.Height {
+				outBeginBlock("linear_extrude(height = " + s.formatFloat(piece.start.Height) + ")")
+				outBeginPolygon()
+				for i, point := range piece.outline {
+					outPoint(point[0], point[1], i == len(piece.outline)-1)
+				}
+				outEndPolygon()
+				outEndBlock()
+				continue
+			}
+
+			// The top of the polyhedron is at the height of the nearest
+			// point along the middle of the segment
+			dx := piece.end.X - piece.start.X
+			dy := piece.end.Y - piece.start.Y
+			bottom := make([][3]float64, len(piece.outline))
+			top := make([][3]float64, len(piece.outline))
+			for i, point := range piece.outline {
+				t := 0.0
+				if dx != 0 || dy != 0 {
+					t = ((point[0]-piece.start.X)*dx + (point[1]-piece.start.Y)*dy) / (dx*dx + dy*dy)
+					t = math.Max(0, math.Min(1, t))
+				}
+				z := piece.start.Height + (piece.end.Height-piece.start.Height)*t
+				bottom[i] = [3]float64{point[0], point[1], 0}
+				top[i] = [3]float64{point[0], point[1], z}
+			}
+			outPolyhedron(bottom, top)
+		}
+		if len(pieces) > 1 {
+			outEndBlock()
+		}
+	}
+
+	// Writes a line as a call to BOSL2's stroke() module, which draws its
+	// outline with round joins.  The outline computed here is only used for
+	// the bounds and checks.
+	writeStroke := func(polygon TurtlePolygon, runs [][][2]float64) {
+		s.usesBOSL2 = true
+		for _, run := range runs {
+			for _, point := range run {
+				x, y := snapPoint(point[0], point[1])
+				s.outline = append(s.outline, [2]float64{x, y})
+			}
+		}
+		addPreview(append([][2]float64(nil), s.outline...))
+		points := make([]string, len(polygon.Points))
+		widths := make([]string, len(polygon.Points))
+		sameWidth := true
+		for i, point := range polygon.Points {
+			x, y := snapPoint(point.X, point.Y)
+			points[i] = "[" + s.formatFloat(x) + "," + s.formatFloat(y) + "],"
+			widths[i] = s.formatFloat(point.Thickness)
+			sameWidth = sameWidth && point.Thickness == polygon.Points[0].Thickness
+		}
+		width := widths[0]
+		if !sameWidth {
+			width = "[" + strings.Join(widths, ", ") + "]"
+		}
+		write(indent(s.indentLevel), "stroke(path = [",
+			lineBreak(s.indentLevel+1), strings.Join(points, pointSeparator),
+			lineBreak(s.indentLevel), "], width = ", width, `, endcaps = "round", joints = "round", $fn = `,
+			strconv.Itoa(polygon.Points[0].EndCapSides), ");\n")
+	}
+
+	writePolygon := func(polygon TurtlePolygon) {
+		s.outline = s.outline[:0]
+		if polygon.Nib != nil && !polygon.ZeroWidth {
+			writeNibPolygon(polygon)
+			return
+		}
+
+		endStrokes := s.opts.Profile.stage("strokes")
+		var runs [][][2]float64
+		if !polygon.ZeroWidth {
+			runs = goscad.Outline(polygon.Points, polygon.Headings)
+		}
+		endStrokes()
+		defer s.opts.Profile.stage("formatting")()
+
+		if s.backend == "bosl2" && !polygon.ZeroWidth && len(polygon.Points) > 1 {
+			writeStroke(polygon, runs)
+			return
+		}
+
+		outBeginPolygon()
+
+		if polygon.ZeroWidth {
+			if len(polygon.Points) == 1 {
+				s.throwError("Zero-width polygon with one point is invalid")
+			}
+			for i, point := range polygon.Points {
+				outPoint(
+					point.X,
+					point.Y,
+					i == len(polygon.Points)-1)
+			}
+			outEndPolygon()
+			return
+		}
+
+		for i, run := range runs {
+			if i > 0 {
+				outNewLine()
+			}
+			for j, point := range run {
+				outPoint(point[0], point[1], j == len(run)-1)
+			}
+		}
+
+		outEndPolygon()
+	}
+
+	// Reports a problem with the script's geometry, if checks are enabled
+	warn := func(format string, a ...interface{}) {
+		if s.opts.Warn != nil {
+			context := s.vm.Context()
+			s.opts.Warn(diagnostic{
+				File:    scriptFilename(context.Filename),
+				Line:    context.Line,
+				Column:  context.Column,
+				Message: fmt.Sprintf(format, a...),
+			})
+		}
+	}
+
+	// Checks the joins of a finished polygon and the outline that was
+	// written for it
+	checkPolygon := func(polygon TurtlePolygon) {
+		if polygon.Nib != nil && !polygon.ZeroWidth {
+			// The outline is made of convex pieces which are always valid
+			return
+		}
+		start := polygon.Points[0]
+		for i := 1; i < len(polygon.Headings) && !polygon.ZeroWidth; i++ {
+			turn := turnAngle(polygon.Headings[i-1], polygon.Headings[i])
+			if miterRatio(turn) > miterLimit {
+				point := polygon.Points[i]
+				warn("Join at [%s, %s] turns too sharply (%s degrees)",
+					s.formatFloat(point.X), s.formatFloat(point.Y), s.formatFloat(turn))
+			}
+		}
+		if selfIntersects(s.outline) {
+			warn("Outline of line starting at [%s, %s] intersects itself",
+				s.formatFloat(start.X), s.formatFloat(start.Y))
+		}
+	}
+
+	// Checks that the outline written for a finished polygon is valid
+	validatePolygon := func(polygon TurtlePolygon) {
+		problem := ""
+		distinct := map[[2]float64]bool{}
+		for _, point := range s.outline {
+			if math.IsNaN(point[0]) || math.IsNaN(point[1]) ||
+				math.IsInf(point[0], 0) || math.IsInf(point[1], 0) {
+				problem = "coordinates are not finite numbers"
+				break
+			}
+			distinct[point] = true
+		}
+		area := signedArea(s.outline)
+		switch {
+		case problem != "":
+		case polygon.Nib != nil && !polygon.ZeroWidth:
+			// The outline is made of convex pieces which are always valid
+		case len(distinct) < 3:
+			problem = "fewer than 3 distinct points"
+		case area == 0:
+			problem = "zero area"
+		case area > 0 && len(polygon.Points) > 1 && !polygon.ZeroWidth:
+			problem = "outline is inside out (counterclockwise)"
+		}
+		if problem != "" {
+			s.throwErrorf("Invalid polygon for the line started on line %d: %s",
+				polygon.Line, problem)
+		}
+	}
+
+	// Writes a finished polygon, along with any modifications and copies
+	// requested by the drawing modes in effect when it was started
+	emitPolygon := func(polygon TurtlePolygon) {
+		endStrokes := s.opts.Profile.stage("strokes")
+		if polygon.FilletRadius > 0 {
+			polygon.Points = roundCorners(polygon.Points, polygon.FilletRadius)
+			polygon.Headings = lineHeadings(polygon.Points)
+		} else if polygon.ChamferSetback > 0 {
+			polygon.Points = bevelCorners(polygon.Points, polygon.ChamferSetback)
+			polygon.Headings = lineHeadings(polygon.Points)
+		}
+		endStrokes()
+		writeHollow := func() {
+			if polygon.Points[0].Height > 0 {
+				writeExtruded(polygon)
+				return
+			}
+			if polygon.Tube {
+				if polygon.HollowWall == 0 {
+					writeTube(polygon, 0)
+					return
+				}
+				outBeginBlock("difference()")
+				writeTube(polygon, 0)
+				writeTube(polygon, polygon.HollowWall)
+				outEndBlock()
+				return
+			}
+			if polygon.HollowWall == 0 {
+				writePolygon(polygon)
+				return
+			}
+			outBeginBlock("difference()")
+			writePolygon(polygon)
+			outBeginBlock("offset(delta = " + s.formatFloat(-polygon.HollowWall) + ")")
+			// The preview doesn't show the hole
+			var shapes [][][2]float64
+			if s.opts.Preview != nil {
+				shapes = s.opts.Preview.Shapes
+			}
+			writePolygon(polygon)
+			if s.opts.Preview != nil {
+				s.opts.Preview.Shapes = shapes
+			}
+			outEndBlock()
+			outEndBlock()
+		}
+
+		writeHollow()
+		// Only flat outlines are checked
+		flat := !polygon.Tube && polygon.Points[0].Height == 0
+		endChecks := s.opts.Profile.stage("checks")
+		if s.opts.Validate && flat {
+			validatePolygon(polygon)
+		}
+		if s.opts.Warn != nil && flat {
+			checkPolygon(polygon)
+		}
+		endChecks()
+		for _, point := range s.outline {
+			s.bounds.add(point[0], point[1])
+			if polygon.Symmetric {
+				s.bounds.add(mirrorPoint(point[0], point[1], polygon.SymmetryAxis))
+			}
+		}
+		if polygon.Symmetric {
+			outBeginBlock(fmt.Sprintf("mirror([%s, %s])",
+				s.formatFloat(-degSin(polygon.SymmetryAxis)),
+				s.formatFloat(degCos(polygon.SymmetryAxis))))
+			mirrored := 0
+			if s.opts.Preview != nil {
+				mirrored = len(s.opts.Preview.Shapes)
+			}
+			writeHollow()
+			if s.opts.Preview != nil {
+				for _, shape := range s.opts.Preview.Shapes[mirrored:] {
+					for i, point := range shape {
+						shape[i][0], shape[i][1] = mirrorPoint(point[0], point[1], polygon.SymmetryAxis)
+					}
+				}
+			}
+			outEndBlock()
+		}
+	}
+
+	// Set up JavaScript interpreter
+	vm := otto.New()
+	s.vm = vm
+
+	// Registers a function which is available to scripts
+	define := func(b builtin, fn func(call otto.FunctionCall) otto.Value) {
+		jsFn := s.toJsValue(fn)
+		vm.Set(b.Name, jsFn)
+		for _, alias := range b.Aliases {
+			vm.Set(alias, jsFn)
+		}
+		s.builtins = append(s.builtins, b)
+	}
+	declare := func(name, value, comment string) {
+		if s.declared[name] {
+			s.throwErrorf("Variable already declared: %s", name)
+		}
+		s.declared[name] = true
+		declaration := name + " = " + value + ";"
+		if comment != "" {
+			declaration += " // " + comment
+		}
+		s.declarations = append(s.declarations, declaration)
+	}
+
+	// Internal turtle operations, shared by the functions below
+	penDown := func() {
+		if !s.turtle.Pendown {
+			s.turtle.Pendown = true
+			s.polygon = TurtlePolygon{
+				Points: []TurtlePoint{{
+					X:           s.turtle.X,
+					Y:           s.turtle.Y,
+					Thickness:   s.turtle.PenSize,
+					EndCapSides: s.turtle.EndCapSides,
+					Height:      s.turtle.PenHeight,
+				}},
+				Headings:           make([]float64, 0),
+				ZeroWidth:          (s.turtle.PenSize == 0),
+				Symmetric:          s.symmetric,
+				SymmetryAxis:       s.symmetryAxis,
+				HollowWall:         s.hollowWall,
+				FilletRadius:       s.filletRadius,
+				ChamferSetback:     s.chamferSetback,
+				PenWidth:           s.penWidth,
+				PenWidthNormalized: s.penWidthNormalized,
+				PenWidthSamples:    s.penWidthSamples,
+				SmoothSamples:      s.smoothSamples,
+				Tube:               s.tube,
+			}
+			if s.tube && s.polygon.ZeroWidth {
+				s.throwError("Tubes can't be drawn with pen size 0")
+			}
+			if s.tube && s.penShape != "round" {
+				s.throwError("Tubes can only be drawn with a round pen")
+			}
+			if s.turtle.PenHeight > 0 && (s.polygon.ZeroWidth || s.penShape != "round" || s.tube || s.hollowWall > 0) {
+				s.throwError("Lines with a height can only be drawn with a round pen, and can't be tubes or hollow")
+			}
+			switch s.penShape {
+			case "square":
+				h := s.turtle.PenSize / 2
+				s.polygon.Nib = [][2]float64{{-h, -h}, {-h, h}, {h, h}, {h, -h}}
+			case "custom":
+				s.polygon.Nib = s.nibPoints
+			}
+			if s.opts.Validate {
+				s.polygon.Line = s.vm.Context().Line
+			}
+		}
+	}
+	// Sets the thickness of each point of a line using its pen width
+	// function
+	applyPenWidth := func(polygon *TurtlePolygon) {
+		total := 0.0
+		for i := 1; i < len(polygon.Points); i++ {
+			total += math.Hypot(
+				polygon.Points[i].X-polygon.Points[i-1].X,
+				polygon.Points[i].Y-polygon.Points[i-1].Y)
+		}
+		polygon.Points, polygon.Headings = resampleLine(
+			polygon.Points, polygon.Headings, total/float64(polygon.PenWidthSamples))
+		distance := 0.0
+		for i := range polygon.Points {
+			if i > 0 {
+				distance += math.Hypot(
+					polygon.Points[i].X-polygon.Points[i-1].X,
+					polygon.Points[i].Y-polygon.Points[i-1].Y)
+			}
+			t := distance / s.unitScale
+			if polygon.PenWidthNormalized {
+				t = 0
+				if total > 0 {
+					t = distance / total
+				}
+			}
+			halfWidth := s.toLength(s.callFunction(polygon.PenWidth, t))
+			if halfWidth < 0 {
+				s.throwError("Pen width function returned less than 0")
+			}
+			polygon.Points[i].Thickness = halfWidth * 2
+		}
+	}
+	penUp := func() {
+		if s.turtle.Pendown {
+			s.turtle.Pendown = false
+			if len(s.polygon.Points) != len(s.polygon.Headings)+1 {
+				s.throwErrorf("Bad polygon: points=%d headings=%d",
+					len(s.polygon.Points),
+					len(s.polygon.Headings))
+			}
+			if s.polygon.Resumed && len(s.polygon.Points) == 1 {
+				return
+			}
+			endStrokes := s.opts.Profile.stage("strokes")
+			if s.polygon.SmoothSamples > 0 {
+				s.polygon.Points = smoothLine(s.polygon.Points, s.polygon.SmoothSamples)
+				s.polygon.Headings = lineHeadings(s.polygon.Points)
+			}
+			if s.polygon.PenWidth.IsFunction() {
+				applyPenWidth(&s.polygon)
+			}
+			endStrokes()
+			if s.recording != nil {
+				s.recording.Strokes = append(s.recording.Strokes, s.polygon.Points)
+				return
+			}
+			emitPolygon(s.polygon)
+		}
+	}
+	addPoint := func(heading float64) {
+		if s.turtle.Pendown {
+			// Lines drawn inside record() are counted when they are
+			// replayed, if they are
+			last := s.polygon.Points[len(s.polygon.Points)-1]
+			if s.recording == nil {
+				s.segments++
+				s.pathLength += math.Hypot(s.turtle.X-last.X, s.turtle.Y-last.Y)
+			}
+			s.polygon.Points = append(s.polygon.Points, TurtlePoint{
+				X:           s.turtle.X,
+				Y:           s.turtle.Y,
+				Thickness:   s.turtle.PenSize,
+				EndCapSides: s.turtle.EndCapSides,
+				Height:      s.turtle.PenHeight,
+			})
+			s.polygon.Headings = append(s.polygon.Headings, heading)
+		}
+	}
+	moveTo := func(x float64, y float64) {
+		if s.turtle.Pendown && x == s.turtle.X && y == s.turtle.Y {
+			warn("Line has identical consecutive points at [%s, %s]",
+				s.formatFloat(x), s.formatFloat(y))
+		}
+		thisHeading := radToDeg(math.Atan2(y-s.turtle.Y, x-s.turtle.X))
+		s.turtle.X = x
+		s.turtle.Y = y
+		addPoint(thisHeading)
+	}
+	moveForward := func(d float64) {
+		if s.turtle.Pendown && d == 0 {
+			warn("Zero-length forward move")
+		}
+		s.turtle.X += d * degCos(s.turtle.Heading)
+		s.turtle.Y += d * degSin(s.turtle.Heading)
+		addPoint(s.turtle.Heading)
+	}
+	// Restores the turtle to a saved state.  If the pen is down, the current
+	// polygon is finished and a new one is started at the new position
+	// (unless nothing has been drawn yet, in which case the polygon is just
+	// moved rather than leaving behind a dot).
+	restoreState := func(state turtleState) {
+		if len(s.polygon.Points) == 1 {
+			s.turtle.Pendown = false
+		}
+		penUp()
+		s.turtle = state
+		if state.Pendown {
+			s.turtle.Pendown = false
+			penDown()
+			s.polygon.Resumed = true
+		}
+	}
+	// Moves the turtle without drawing.
+	jumpTo := func(x float64, y float64) {
+		state := s.turtle
+		state.X = x
+		state.Y = y
+		restoreState(state)
+	}
+	pushState := func() {
+		s.savedStates = append(s.savedStates, s.turtle)
+	}
+	popState := func() {
+		if len(s.savedStates) == 0 {
+			s.throwError("pop() called without a matching push()")
+		}
+		state := s.savedStates[len(s.savedStates)-1]
+		s.savedStates = s.savedStates[:len(s.savedStates)-1]
+		restoreState(state)
+	}
+	// Draws the lines in a recorded path, leaving the pen up
+	replayPath := func(path *recordedPath) {
+		penUp()
+		penSize, endCapSides, penHeight := s.turtle.PenSize, s.turtle.EndCapSides, s.turtle.PenHeight
+		for _, stroke := range path.Strokes {
+			for i, point := range stroke {
+				s.turtle.PenSize = point.Thickness
+				s.turtle.EndCapSides = point.EndCapSides
+				s.turtle.PenHeight = point.Height
+				if i == 0 {
+					jumpTo(point.X, point.Y)
+					penDown()
+				} else {
+					moveTo(point.X, point.Y)
+				}
+			}
+			penUp()
+		}
+		s.turtle.PenSize, s.turtle.EndCapSides, s.turtle.PenHeight = penSize, endCapSides, penHeight
+	}
+	// Runs a function which draws lines with the pen up or down, then puts
+	// the pen back as it was before without leaving a dot behind
+	keepPen := func(drawLines func()) {
+		wasPendown := s.turtle.Pendown
+		if len(s.polygon.Points) == 1 {
+			s.polygon.Resumed = true
+		}
+		drawLines()
+		if wasPendown {
+			penDown()
+			s.polygon.Resumed = true
+		}
+	}
+	// Creates the JavaScript object returned by record()
+	var newPathObject func(path *recordedPath) otto.Value
+	newPathObject = func(path *recordedPath) otto.Value {
+		object, _ := vm.Object("({})")
+		object.Set("__path", path)
+		object.Set("replay", func(call otto.FunctionCall) otto.Value {
+			keepPen(func() { replayPath(path) })
+			return otto.UndefinedValue()
+		})
+		object.Set("translate", func(call otto.FunctionCall) otto.Value {
+			return newPathObject(path.translate(
+				s.toLength(call.Argument(0)),
+				s.toLength(call.Argument(1))))
+		})
+		object.Set("rotate", func(call otto.FunctionCall) otto.Value {
+			return newPathObject(path.rotate(s.toFloat(call.Argument(0))))
+		})
+		object.Set("scale", func(call otto.FunctionCall) otto.Value {
+			sx := s.toFloat(call.Argument(0))
+			sy := sx
+			if !call.Argument(1).IsUndefined() {
+				sy = s.toFloat(call.Argument(1))
+			}
+			return newPathObject(path.scale(sx, sy))
+		})
+		object.Set("reverse", func(call otto.FunctionCall) otto.Value {
+			return newPathObject(path.reverse())
+		})
+		object.Set("strokes", func(call otto.FunctionCall) otto.Value {
+			strokes := make([][][2]float64, len(path.Strokes))
+			for i, stroke := range path.Strokes {
+				strokes[i] = make([][2]float64, len(stroke))
+				for j, point := range stroke {
+					strokes[i][j] = [2]float64{
+						point.X / s.unitScale,
+						point.Y / s.unitScale,
+					}
+				}
+			}
+			return s.toJsValue(strokes)
+		})
+		return object.Value()
+	}
+
+	// Reads a data file loaded by the script.  Scripts may only read files
+	// inside the directory containing the input file.
+	readDataFile := func(filename string) []byte {
+		if s.opts.BaseDir == "" {
+			s.throwErrorf("Data file %s cannot be loaded in this context", filename)
+		}
+		cleaned := filepath.Clean(filename)
+		if filepath.IsAbs(cleaned) || cleaned == ".." ||
+			strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+			s.throwErrorf("Data file %s is outside the input file's directory", filename)
+		}
+		path := filepath.Join(s.opts.BaseDir, cleaned)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			s.throwError(err.Error())
+		}
+		s.dataFiles[path] = sha256.Sum256(data)
+		return data
+	}
+
+	// Set up functions
+	define(builtin{
+		Name:       "pendown",
+		Signatures: []string{"(): void"},
+		Doc:        "Puts the pen down, so that moving the turtle draws a line.",
+		Aliases:    []string{"pd", "down"},
+	}, func(call otto.FunctionCall) otto.Value {
+		penDown()
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "penup",
+		Signatures: []string{"(): void"},
+		Doc:        "Lifts the pen up and writes the line drawn since it was put down.",
+		Aliases:    []string{"pu", "up"},
+	}, func(call otto.FunctionCall) otto.Value {
+		penUp()
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "pensize",
+		Signatures: []string{"(): number", "(size: number): void"},
+		Doc:        "Gets or sets the thickness of the lines drawn by the pen.",
+		Aliases:    []string{"width"},
+	}, func(call otto.FunctionCall) otto.Value {
+		if call.Argument(0).IsUndefined() {
+			return s.fromLength(s.turtle.PenSize)
+		}
+		s.turtle.PenSize = s.toLength(call.Argument(0))
+		if s.turtle.PenSize < 0 {
+			s.throwError("Pen size set to less than 0")
+		} else if s.turtle.Pendown && s.polygon.ZeroWidth && s.turtle.PenSize > 0 {
+			s.throwError("Polygon was started with pen size 0 and then set to non-zero")
+		} else if s.turtle.Pendown && !s.polygon.ZeroWidth && s.turtle.PenSize == 0 {
+			s.throwError("Polygon was started with non-zero pen size and then set to 0")
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "penheight",
+		Signatures: []string{"(): number", "(height: number): void"},
+		Doc: "Gets or sets the height that lines are drawn with in 3D, for ramps\n" +
+			"and terraces.  The height is recorded for each point.  Each segment\n" +
+			"of a line with a height is extruded separately, and slopes between\n" +
+			"the heights at its ends.  0 draws flat lines again.",
+	}, func(call otto.FunctionCall) otto.Value {
+		if call.Argument(0).IsUndefined() {
+			return s.fromLength(s.turtle.PenHeight)
+		}
+		s.turtle.PenHeight = s.toLength(call.Argument(0))
+		if s.turtle.PenHeight < 0 {
+			s.throwError("Pen height set to less than 0")
+		} else if s.turtle.Pendown && s.polygon.Points[0].Height == 0 && s.turtle.PenHeight > 0 {
+			s.throwError("Polygon was started with pen height 0 and then set to non-zero")
+		} else if s.turtle.Pendown && s.polygon.Points[0].Height > 0 && s.turtle.PenHeight == 0 {
+			s.throwError("Polygon was started with non-zero pen height and then set to 0")
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "end_cap_sides",
+		Signatures: []string{"(): number", "(sides: number): void"},
+		Doc: "Gets or sets the number of sides used to draw a full circle at the ends\n" +
+			"of lines.  Must be even.",
+	}, func(call otto.FunctionCall) otto.Value {
+		if call.Argument(0).IsUndefined() {
+			return s.toJsValue(s.turtle.EndCapSides)
+		}
+		s.turtle.EndCapSides = s.toInt(call.Argument(0))
+		if s.turtle.EndCapSides < 2 || s.turtle.EndCapSides%2 == 1 {
+			s.throwErrorf("Invalid end_cap_sides value: %d", s.turtle.EndCapSides)
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "forward",
+		Signatures: []string{"(distance: number): void"},
+		Doc:        "Moves the turtle forward in the direction it is facing.",
+	}, func(call otto.FunctionCall) otto.Value {
+		moveForward(s.toLength(call.Argument(0)))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "move",
+		Signatures: []string{"(distance: number): void"},
+		Doc: "Moves the turtle forward in the direction it is facing without\n" +
+			"drawing.  If the pen is down, the line being drawn ends here and a\n" +
+			"new one starts at the new position.",
+	}, func(call otto.FunctionCall) otto.Value {
+		d := s.toLength(call.Argument(0))
+		jumpTo(s.turtle.X+d*degCos(s.turtle.Heading), s.turtle.Y+d*degSin(s.turtle.Heading))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "jump",
+		Signatures: []string{"(dx: number, dy: number): void"},
+		Doc: "Moves the turtle by the given offsets without drawing.  If the pen is\n" +
+			"down, the line being drawn ends here and a new one starts at the new\n" +
+			"position.",
+	}, func(call otto.FunctionCall) otto.Value {
+		jumpTo(
+			s.turtle.X+s.toLength(call.Argument(0)),
+			s.turtle.Y+s.toLength(call.Argument(1)))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "right",
+		Signatures: []string{"(angle: number): void"},
+		Doc:        "Turns the turtle clockwise by the given angle in degrees.",
+		Aliases:    []string{"rt"},
+	}, func(call otto.FunctionCall) otto.Value {
+		s.turtle.Heading -= s.toFloat(call.Argument(0))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "left",
+		Signatures: []string{"(angle: number): void"},
+		Doc:        "Turns the turtle counterclockwise by the given angle in degrees.",
+		Aliases:    []string{"lt"},
+	}, func(call otto.FunctionCall) otto.Value {
+		s.turtle.Heading += s.toFloat(call.Argument(0))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "setpos",
+		Signatures: []string{"(x: number, y: number): void"},
+		Doc:        "Moves the turtle to the given position without changing its heading.",
+		// Note, no `goto` alias (reserved word)
+		Aliases: []string{"setposition"},
+	}, func(call otto.FunctionCall) otto.Value {
+		moveTo(s.toLength(call.Argument(0)), s.toLength(call.Argument(1)))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "polar",
+		Signatures: []string{"(r: number, theta: number): void"},
+		Doc: "Moves the turtle to the point at distance r from the origin, at an\n" +
+			"angle of theta degrees counterclockwise from the X axis.",
+	}, func(call otto.FunctionCall) otto.Value {
+		r := s.toLength(call.Argument(0))
+		theta := s.toFloat(call.Argument(1))
+		moveTo(r*degCos(theta), r*degSin(theta))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "trace",
+		Signatures: []string{"(fn: (t: number) => number[], tStart: number, tEnd: number, steps: number): void"},
+		Doc: "Traces a parametric curve by calling fn with steps + 1 evenly spaced\n" +
+			"values of t from tStart to tEnd.  fn returns the [x, y] point on the\n" +
+			"curve for each value.  The turtle moves to the first point without\n" +
+			"drawing, then through the rest of the points, and finally faces in\n" +
+			"the direction of the end of the curve.",
+	}, func(call otto.FunctionCall) otto.Value {
+		tStart := s.toFloat(call.Argument(1))
+		tEnd := s.toFloat(call.Argument(2))
+		steps := s.toInt(call.Argument(3))
+		if steps < 1 {
+			s.throwErrorf("Invalid trace steps value: %d", steps)
+		}
+		for i := 0; i <= steps; i++ {
+			t := tStart + (tEnd-tStart)*float64(i)/float64(steps)
+			point := s.toPoint(s.callFunction(call.Argument(0), t))
+			x, y := point[0]*s.unitScale, point[1]*s.unitScale
+			if i == 0 {
+				jumpTo(x, y)
+			} else {
+				if x != s.turtle.X || y != s.turtle.Y {
+					s.turtle.Heading = radToDeg(math.Atan2(y-s.turtle.Y, x-s.turtle.X))
+				}
+				moveTo(x, y)
+			}
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "arc_to",
+		Signatures: []string{"(x: number, y: number, r: number, direction?: \"left\" | \"right\"): void"},
+		Doc: "Moves the turtle to (x, y) along the shorter circular arc of radius r,\n" +
+			"curving to the left (counterclockwise, the default) or to the right.\n" +
+			"The arc is drawn with one segment for every 360 / end_cap_sides()\n" +
+			"degrees, and the turtle ends up facing along the end of the arc.",
+	}, func(call otto.FunctionCall) otto.Value {
+		x := s.toLength(call.Argument(0))
+		y := s.toLength(call.Argument(1))
+		r := s.toLength(call.Argument(2))
+		side := 1.0
+		if direction := call.Argument(3); direction.IsDefined() {
+			switch s.toString(direction) {
+			case "left":
+			case "right":
+				side = -1
+			default:
+				s.throwErrorf("Invalid arc direction: %q", s.toString(direction))
+			}
+		}
+		chord := math.Hypot(x-s.turtle.X, y-s.turtle.Y)
+		if chord == 0 {
+			return otto.UndefinedValue()
+		}
+		if r < chord/2*(1-1e-9) {
+			s.throwErrorf("Arc radius %s is too small to reach the target point",
+				s.formatFloat(r/s.unitScale))
+		}
+
+		// The center is on the side of the chord that the arc curves
+		// towards
+		h := math.Sqrt(math.Max(0, r*r-chord*chord/4))
+		chordHeading := radToDeg(math.Atan2(y-s.turtle.Y, x-s.turtle.X))
+		centerX := (s.turtle.X+x)/2 + h*degCos(chordHeading+90*side)
+		centerY := (s.turtle.Y+y)/2 + h*degSin(chordHeading+90*side)
+		start := radToDeg(math.Atan2(s.turtle.Y-centerY, s.turtle.X-centerX))
+		end := radToDeg(math.Atan2(y-centerY, x-centerX))
+		sweep := math.Mod(end-start+720, 360) * side
+		if side < 0 {
+			sweep = -math.Mod(start-end+720, 360)
+		}
+
+		steps := int(math.Ceil(math.Abs(sweep) * float64(s.turtle.EndCapSides) / 360))
+		if steps < 1 {
+			steps = 1
+		}
+		for i := 1; i <= steps; i++ {
+			if i == steps {
+				moveTo(x, y)
+			} else {
+				angle := start + sweep*float64(i)/float64(steps)
+				moveTo(centerX+r*degCos(angle), centerY+r*degSin(angle))
+			}
+		}
+		s.turtle.Heading = end + 90*side
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "heading",
+		Signatures: []string{"(): number"},
+		Doc:        "Returns the direction the turtle is facing in degrees.",
+	}, func(call otto.FunctionCall) otto.Value {
+		return s.toJsValue(s.turtle.Heading)
+	})
+	define(builtin{
+		Name:       "pathlength",
+		Signatures: []string{"(): number"},
+		Doc: "Returns the total length of the lines drawn so far.  Lines drawn\n" +
+			"inside record() are counted each time they are replayed.",
+	}, func(call otto.FunctionCall) otto.Value {
+		return s.fromLength(s.pathLength)
+	})
+	define(builtin{
+		Name:       "segments",
+		Signatures: []string{"(): number"},
+		Doc: "Returns the number of times the turtle has moved with the pen\n" +
+			"down so far.  Lines rounded by smooth() or fillet() count as the\n" +
+			"moves they were drawn with, not the segments of their curves.",
+	}, func(call otto.FunctionCall) otto.Value {
+		return s.toJsValue(s.segments)
+	})
+	define(builtin{
+		Name:       "push",
+		Signatures: []string{"(): void"},
+		Doc: "Saves the turtle's position, heading, pen state and pen settings, so\n" +
+			"that they can be restored with pop().",
+	}, func(call otto.FunctionCall) otto.Value {
+		pushState()
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "pop",
+		Signatures: []string{"(): void"},
+		Doc: "Restores the turtle state saved by the most recent call to push().\n" +
+			"The turtle does not draw while moving back to the saved position.",
+	}, func(call otto.FunctionCall) otto.Value {
+		popState()
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "repeat",
+		Signatures: []string{"(count: number, fn: (i: number) => void): void"},
+		Doc:        "Calls fn count times, passing the number of the repetition (from 0).",
+	}, func(call otto.FunctionCall) otto.Value {
+		count := s.toInt(call.Argument(0))
+		for i := 0; i < count; i++ {
+			s.callFunction(call.Argument(1), i)
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "symmetry",
+		Signatures: []string{"(): number | false", "(axis: \"x\" | \"y\" | number | false): void"},
+		Doc: "Gets or sets the axis that lines are mirrored across.  The axis may\n" +
+			"be \"x\", \"y\", or the angle in degrees of a line through the origin.\n" +
+			"false turns off mirroring.  Takes effect the next time the pen is put\n" +
+			"down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		axis := call.Argument(0)
+		if axis.IsUndefined() {
+			if !s.symmetric {
+				return otto.FalseValue()
+			}
+			return s.toJsValue(s.symmetryAxis)
+		}
+		switch {
+		case axis.IsBoolean():
+			if b, _ := axis.ToBoolean(); b {
+				s.throwError("symmetry(true) is ambiguous; specify an axis")
+			}
+			s.symmetric = false
+		case axis.IsString() && s.toString(axis) == "x":
+			s.symmetric = true
+			s.symmetryAxis = 0
+		case axis.IsString() && s.toString(axis) == "y":
+			s.symmetric = true
+			s.symmetryAxis = 90
+		case axis.IsNumber():
+			s.symmetric = true
+			s.symmetryAxis = s.toFloat(axis)
+		default:
+			s.throwErrorf("Invalid symmetry axis: %s", axis.String())
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "record",
+		Signatures: []string{"(fn: () => void): Path"},
+		Doc: "Calls fn and records the lines it draws instead of writing them to the\n" +
+			"output.  Afterwards the turtle is returned to its previous state.  The\n" +
+			"returned path has the methods replay(), translate(dx, dy),\n" +
+			"rotate(angle), scale(s) or scale(sx, sy), reverse() and strokes().\n" +
+			"Transformations return a new path and are relative to the origin.",
+	}, func(call otto.FunctionCall) otto.Value {
+		// Start a separate line if the pen is down, and put everything back
+		// afterwards
+		state, polygon, outerRecording := s.turtle, s.polygon, s.recording
+		path := &recordedPath{}
+		s.recording = path
+		if s.turtle.Pendown {
+			s.turtle.Pendown = false
+			penDown()
+			s.polygon.Resumed = true
+		}
+		s.callFunction(call.Argument(0))
+		penUp()
+		s.turtle, s.polygon, s.recording = state, polygon, outerRecording
+		return newPathObject(path)
+	})
+	define(builtin{
+		Name:       "lsystem",
+		Signatures: []string{"(axiom: string, rules: {[symbol: string]: string}, iterations: number, actions: {[symbol: string]: number | (() => void)}): void"},
+		Doc: "Expands an L-system by applying the given rules to the axiom the\n" +
+			"given number of times, then draws the result.  actions gives the\n" +
+			"distance to move for a letter (drawing if the letter is uppercase,\n" +
+			"otherwise moving without drawing) or the angle to turn left for \"+\"\n" +
+			"and right for \"-\" (defaulting to the angle for \"+\"), or a function\n" +
+			"to call.  \"[\" and \"]\" push and pop the turtle state, \"|\" turns\n" +
+			"around, and other symbols are ignored.",
+	}, func(call otto.FunctionCall) otto.Value {
+		axiom := s.toString(call.Argument(0))
+		rules := map[rune]string{}
+		for symbol, rule := range s.toObjectMap(call.Argument(1)) {
+			rules[symbol] = s.toString(rule)
+		}
+		iterations := s.toInt(call.Argument(2))
+		actions := s.toObjectMap(call.Argument(3))
+		if _, ok := actions['-']; !ok {
+			if turn, ok := actions['+']; ok {
+				actions['-'] = turn
+			}
+		}
+
+		commands, err := expandLsystem(axiom, rules, iterations)
+		if err != nil {
+			s.throwError(err.Error())
+		}
+		for _, symbol := range commands {
+			action, hasAction := actions[symbol]
+			if hasAction && action.IsFunction() {
+				s.callFunction(action)
+				continue
+			}
+			switch {
+			case symbol == '[':
+				pushState()
+			case symbol == ']':
+				popState()
+			case symbol == '|':
+				s.turtle.Heading += 180
+			case !hasAction:
+				// Symbols without actions only control the expansion
+			case symbol == '+':
+				s.turtle.Heading += s.toFloat(action)
+			case symbol == '-':
+				s.turtle.Heading -= s.toFloat(action)
+			case unicode.IsUpper(symbol):
+				moveForward(s.toLength(action))
+			default:
+				d := s.toLength(action)
+				jumpTo(
+					s.turtle.X+d*degCos(s.turtle.Heading),
+					s.turtle.Y+d*degSin(s.turtle.Heading))
+			}
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "hollow",
+		Signatures: []string{"(): number", "(wall: number | false): void"},
+		Doc: "Gets or sets the thickness of the walls that lines are drawn with.\n" +
+			"Each line becomes an outline of this thickness instead of being\n" +
+			"solid.  0 or false draws solid lines again.  Takes effect the next\n" +
+			"time the pen is put down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		wall := call.Argument(0)
+		if wall.IsUndefined() {
+			return s.fromLength(s.hollowWall)
+		}
+		if wall.IsBoolean() {
+			if b, _ := wall.ToBoolean(); b {
+				s.throwError("hollow(true) is ambiguous; specify a wall thickness")
+			}
+			s.hollowWall = 0
+		} else {
+			s.hollowWall = s.toLength(wall)
+			if s.hollowWall < 0 {
+				s.throwError("Hollow wall thickness set to less than 0")
+			}
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "tube",
+		Signatures: []string{"(): boolean", "(enabled: boolean): void"},
+		Doc: "Gets or sets whether lines are drawn in 3D as tubes with a round\n" +
+			"cross-section as thick as the pen, instead of as flat outlines.  Each\n" +
+			"segment is the convex hull of spheres at its ends, with\n" +
+			"end_cap_sides() sides.  Hollow tubes are closed at the ends.  Takes\n" +
+			"effect the next time the pen is put down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		if call.Argument(0).IsUndefined() {
+			return s.toJsValue(s.tube)
+		}
+		s.tube, _ = call.Argument(0).ToBoolean()
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "penshape",
+		Signatures: []string{"(): \"round\" | \"square\" | Array<[number, number]>", "(shape: \"round\" | \"square\" | Array<[number, number]>): void"},
+		Doc: "Gets or sets the shape of the pen: \"round\" (the default), \"square\"\n" +
+			"(with sides as long as the pen size, and not rotated as the turtle\n" +
+			"turns), or a convex polygon given as a list of [x, y] offsets from\n" +
+			"the turtle's position.  Lines are drawn by sweeping the pen along\n" +
+			"them.  Takes effect the next time the pen is put down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		shape := call.Argument(0)
+		if shape.IsUndefined() {
+			if s.penShape != "custom" {
+				return s.toJsValue(s.penShape)
+			}
+			points := make([][]float64, len(s.nibPoints))
+			for i, point := range s.nibPoints {
+				points[i] = []float64{point[0] / s.unitScale, point[1] / s.unitScale}
+			}
+			return s.toJsValue(points)
+		}
+		if shape.IsString() {
+			name := s.toString(shape)
+			if name != "round" && name != "square" {
+				s.throwErrorf("Unknown pen shape: %q", name)
+			}
+			s.penShape = name
+			s.nibPoints = nil
+			return otto.UndefinedValue()
+		}
+		points := s.toPoints(shape)
+		for i := range points {
+			points[i][0] *= s.unitScale
+			points[i][1] *= s.unitScale
+		}
+		if len(convexHull(points)) < 2 {
+			s.throwError("Pen shape needs at least 2 distinct points")
+		}
+		s.penShape = "custom"
+		s.nibPoints = points
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "calligraphy",
+		Signatures: []string{"(width: number, angle: number, thickness?: number): void"},
+		Doc: "Sets the shape of the pen to a flat nib of the given width, held at\n" +
+			"a fixed angle (in degrees counterclockwise from the X axis), so that\n" +
+			"lines are thickest when drawn across the nib and thinnest when drawn\n" +
+			"along it.  thickness (default 0) is the width of these thinnest\n" +
+			"lines.  Use penshape(\"round\") to go back to a round pen.  Takes\n" +
+			"effect the next time the pen is put down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		width := s.toLength(call.Argument(0))
+		angle := s.toFloat(call.Argument(1))
+		thickness := 0.0
+		if call.Argument(2).IsDefined() {
+			thickness = s.toLength(call.Argument(2))
+		}
+		if width <= 0 {
+			s.throwErrorf("Calligraphy pen width must be greater than 0, not %s",
+				s.formatFloat(width/s.unitScale))
+		}
+		if thickness < 0 {
+			s.throwErrorf("Calligraphy pen thickness must be positive (or 0 for a flat nib), not %s",
+				s.formatFloat(thickness/s.unitScale))
+		}
+		// Half of the nib's width and thickness, along and across the nib
+		wx, wy := width/2*degCos(angle), width/2*degSin(angle)
+		tx, ty := -thickness/2*degSin(angle), thickness/2*degCos(angle)
+		s.penShape = "custom"
+		s.nibPoints = [][2]float64{{-wx, -wy}, {wx, wy}}
+		if thickness > 0 {
+			s.nibPoints = [][2]float64{
+				{-wx - tx, -wy - ty}, {wx - tx, wy - ty},
+				{wx + tx, wy + ty}, {-wx + tx, -wy + ty},
+			}
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name: "penwidth",
+		Signatures: []string{
+			"(): ((t: number) => number) | false",
+			"(fn: ((t: number) => number) | false, options?: {normalized?: boolean, samples?: number}): void",
+		},
+		Doc: "Gets or sets a function which gives half the thickness of each line\n" +
+			"at a distance t along it, replacing the pen size.  If the normalized\n" +
+			"option is true, t goes from 0 at the start of the line to 1 at the\n" +
+			"end.  Each line is split into the given number of samples (default\n" +
+			"32) so that its thickness changes smoothly.  false draws lines with\n" +
+			"the pen size again.  Takes effect the next time the pen is put down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		fn := call.Argument(0)
+		if fn.IsUndefined() {
+			if s.penWidth.IsFunction() {
+				return s.penWidth
+			}
+			return s.toJsValue(false)
+		}
+		if fn.IsBoolean() {
+			if b, _ := fn.ToBoolean(); b {
+				s.throwError("penwidth(true) is ambiguous; specify a function")
+			}
+			s.penWidth = otto.UndefinedValue()
+			return otto.UndefinedValue()
+		}
+		if !fn.IsFunction() {
+			s.throwError("penwidth() needs a function or false")
+		}
+		options := call.Argument(1)
+		s.penWidth = fn
+		s.penWidthNormalized = false
+		if normalized := s.getProperty(options, "normalized"); normalized.IsDefined() {
+			s.penWidthNormalized, _ = normalized.ToBoolean()
+		}
+		s.penWidthSamples = 32
+		if samples := s.getProperty(options, "samples"); samples.IsDefined() {
+			s.penWidthSamples = s.toInt(samples)
+			if s.penWidthSamples < 1 {
+				s.throwError("Pen width samples set to less than 1")
+			}
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "smooth",
+		Signatures: []string{"(): number", "(samples: number | boolean): void"},
+		Doc: "Gets or sets the number of samples between each pair of points when\n" +
+			"lines are drawn as smooth curves (Catmull-Rom splines) through the\n" +
+			"points the turtle moves to, instead of straight segments.  true uses\n" +
+			"8 samples, and 0 or false draws straight segments again.  Takes\n" +
+			"effect the next time the pen is put down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		samples := call.Argument(0)
+		if samples.IsUndefined() {
+			return s.toJsValue(s.smoothSamples)
+		}
+		if samples.IsBoolean() {
+			s.smoothSamples = 0
+			if b, _ := samples.ToBoolean(); b {
+				s.smoothSamples = 8
+			}
+		} else {
+			s.smoothSamples = s.toInt(samples)
+			if s.smoothSamples < 0 {
+				s.throwError("Smooth samples set to less than 0")
+			}
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "fillet",
+		Signatures: []string{"(): number", "(radius: number | false): void"},
+		Doc: "Gets or sets the radius that corners are rounded to.  The inside\n" +
+			"edge of each corner becomes an arc of this radius, and the outside\n" +
+			"edge an arc around the same center.  0 or false draws sharp corners\n" +
+			"again.  Replaces chamfer().  Takes effect the next time the pen is\n" +
+			"put down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		radius := call.Argument(0)
+		if radius.IsUndefined() {
+			return s.fromLength(s.filletRadius)
+		}
+		if radius.IsBoolean() {
+			if b, _ := radius.ToBoolean(); b {
+				s.throwError("fillet(true) is ambiguous; specify a radius")
+			}
+			s.filletRadius = 0
+		} else {
+			s.filletRadius = s.toLength(radius)
+			if s.filletRadius < 0 {
+				s.throwError("Fillet radius set to less than 0")
+			}
+		}
+		s.chamferSetback = 0
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "chamfer",
+		Signatures: []string{"(): number", "(setback: number | false): void"},
+		Doc: "Gets or sets how far from each corner the corner is cut off with a\n" +
+			"straight bevel, measured along the middle of the line.  0 or false\n" +
+			"draws sharp corners again.  Replaces fillet().  Takes effect the\n" +
+			"next time the pen is put down.",
+	}, func(call otto.FunctionCall) otto.Value {
+		setback := call.Argument(0)
+		if setback.IsUndefined() {
+			return s.fromLength(s.chamferSetback)
+		}
+		if setback.IsBoolean() {
+			if b, _ := setback.ToBoolean(); b {
+				s.throwError("chamfer(true) is ambiguous; specify a setback")
+			}
+			s.chamferSetback = 0
+		} else {
+			s.chamferSetback = s.toLength(setback)
+			if s.chamferSetback < 0 {
+				s.throwError("Chamfer setback set to less than 0")
+			}
+		}
+		s.filletRadius = 0
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "layer",
+		Signatures: []string{"(name: string, fn: () => void): void"},
+		Doc: "Calls fn and collects the OpenSCAD code it generates into a module\n" +
+			"named layer_<name>.  The module is used where layer() is first\n" +
+			"called with this name, if the variable show_<name> is true, which\n" +
+			"allows the layer to be turned off in OpenSCAD's Customizer.  Later\n" +
+			"calls add to the same module.",
+	}, func(call otto.FunctionCall) otto.Value {
+		name := s.toString(call.Argument(0))
+		if !identifierPattern.MatchString(name) {
+			s.throwErrorf("Invalid layer name: %q", name)
+		}
+		// The module's name is prefixed so that it can't replace one of
+		// OpenSCAD's modules, e.g. polygon() calling itself forever
+		moduleName := "layer_" + name
+		var module *scadModule
+		for _, m := range s.modules {
+			if m.Name == moduleName {
+				module = m
+			}
+		}
+		if module == nil {
+			module = &scadModule{Name: moduleName}
+			s.modules = append(s.modules, module)
+			declare("show_"+name, "true", "")
+			write(indent(s.indentLevel), "if (show_", name, ") ", moduleName, "();\n")
+		}
+
+		// Lines which are being drawn are split where the layer starts and
+		// ends, but the turtle keeps moving as usual
+		pendown, out, indentLevel := s.turtle.Pendown, s.out, s.indentLevel
+		if len(s.polygon.Points) == 1 {
+			s.polygon.Resumed = true
+		}
+		penUp()
+		s.out, s.indentLevel = &module.Body, 1
+		if pendown {
+			penDown()
+			s.polygon.Resumed = true
+		}
+		s.callFunction(call.Argument(1))
+		pendown = s.turtle.Pendown
+		penUp()
+		s.out, s.indentLevel = out, indentLevel
+		if pendown {
+			penDown()
+			s.polygon.Resumed = true
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "param",
+		Signatures: []string{"(name: string, value: number | string | boolean, options?: {min?: number, max?: number, step?: number, choices?: Array<number | string | [number | string, string]>, description?: string}): number | string | boolean"},
+		Doc: "Declares a variable at the top of the output which can be changed in\n" +
+			"OpenSCAD's Customizer, and returns its value.  options may give a\n" +
+			"description, a slider range (max, and optionally min and step), or a\n" +
+			"dropdown list of choices, each a value or a [value, label] pair.",
+	}, func(call otto.FunctionCall) otto.Value {
+		name := s.toString(call.Argument(0))
+		if !identifierPattern.MatchString(name) {
+			s.throwErrorf("Invalid parameter name: %q", name)
+		}
+		value := call.Argument(1)
+		scadValue := s.toScadValue(value)
+		annotation := ""
+		options := call.Argument(2)
+		get := func(key string) otto.Value {
+			return s.getProperty(options, key)
+		}
+		if description := get("description"); description.IsDefined() {
+			s.declarations = append(s.declarations, "// "+s.toString(description))
+		}
+		if choices := get("choices"); choices.IsDefined() {
+			if !choices.IsObject() {
+				s.throwError("Parameter choices must be an array")
+			}
+			var items []string
+			for _, key := range choices.Object().Keys() {
+				choice, _ := choices.Object().Get(key)
+				label := ""
+				if choice.IsObject() {
+					labelValue, _ := choice.Object().Get("1")
+					label = s.toString(labelValue)
+					choice, _ = choice.Object().Get("0")
+				}
+				item := s.toString(choice)
+				if choice.IsNumber() {
+					item = s.formatFloat(s.toFloat(choice))
+				}
+				if label != "" {
+					item += ":" + label
+				}
+				items = append(items, item)
+			}
+			annotation = "[" + strings.Join(items, ", ") + "]"
+		} else if max := get("max"); max.IsDefined() {
+			bounds := []string{s.formatFloat(s.toFloat(max))}
+			if step := get("step"); step.IsDefined() {
+				bounds = append([]string{s.formatFloat(s.toFloat(step))}, bounds...)
+			}
+			if min := get("min"); min.IsDefined() {
+				bounds = append([]string{s.formatFloat(s.toFloat(min))}, bounds...)
+			} else if len(bounds) > 1 {
+				bounds = append([]string{"0"}, bounds...)
+			}
+			annotation = "[" + strings.Join(bounds, ":") + "]"
+		}
+		declare(name, scadValue, annotation)
+		return value
+	})
+	define(builtin{
+		Name:       "param_group",
+		Signatures: []string{"(name: string): void"},
+		Doc: "Starts a new group (shown as a tab or section in OpenSCAD's\n" +
+			"Customizer) for the variables declared by later calls to param() and\n" +
+			"layer().",
+	}, func(call otto.FunctionCall) otto.Value {
+		name := s.toString(call.Argument(0))
+		if strings.ContainsAny(name, "[]*/\n") {
+			s.throwErrorf("Invalid parameter group name: %q", name)
+		}
+		if len(s.declarations) > 0 {
+			s.declarations = append(s.declarations, "")
+		}
+		s.declarations = append(s.declarations, "/* ["+name+"] */")
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "anchor",
+		Signatures: []string{"(name: string): void", "(name: string, x: number, y: number, heading?: number): void"},
+		Doc: "Names a point where other parts can be attached to the drawing, by\n" +
+			"hand-written OpenSCAD code which includes the output.  It is written as\n" +
+			"a variable anchor_<name> = [x, y, heading].  Uses the turtle's position\n" +
+			"and heading by default.",
+	}, func(call otto.FunctionCall) otto.Value {
+		name := s.toString(call.Argument(0))
+		variable := "anchor_" + name
+		if !identifierPattern.MatchString(variable) {
+			s.throwErrorf("Invalid anchor name: %q", name)
+		}
+		if s.declared[variable] {
+			s.throwErrorf("Variable already declared: %s", variable)
+		}
+		s.declared[variable] = true
+		anchor := scadAnchor{Name: name, X: s.turtle.X, Y: s.turtle.Y, Heading: s.turtle.Heading}
+		if call.Argument(1).IsDefined() {
+			anchor.X = s.toLength(call.Argument(1))
+			anchor.Y = s.toLength(call.Argument(2))
+		}
+		if call.Argument(3).IsDefined() {
+			anchor.Heading = s.toFloat(call.Argument(3))
+		}
+		s.anchors = append(s.anchors, anchor)
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "backend",
+		Signatures: []string{"(): \"polygon\" | \"bosl2\"", "(name: \"polygon\" | \"bosl2\"): void"},
+		Doc: "Gets or sets how lines are written to the output: \"polygon\" (the\n" +
+			"default) writes polygons of their outlines, and \"bosl2\" writes their\n" +
+			"paths as calls to the BOSL2 library's stroke() module, which has round\n" +
+			"joins.  Dots and lines drawn with other pen shapes are always written\n" +
+			"as polygons.",
+	}, func(call otto.FunctionCall) otto.Value {
+		if call.Argument(0).IsUndefined() {
+			return s.toJsValue(s.backend)
+		}
+		name := s.toString(call.Argument(0))
+		for _, backend := range outputBackends {
+			if name == backend {
+				s.backend = name
+				return otto.UndefinedValue()
+			}
+		}
+		s.throwErrorf("Invalid backend: %q", name)
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "autounion",
+		Signatures: []string{"(): boolean", "(enabled: boolean): void"},
+		Doc: "Gets or sets whether the whole drawing is wrapped in a single union()\n" +
+			"block, so that it is one 2D region for operations such as\n" +
+			"linear_extrude() and difference().  Off by default, unless the\n" +
+			"--union-all flag is given.",
+	}, func(call otto.FunctionCall) otto.Value {
+		if call.Argument(0).IsUndefined() {
+			return s.toJsValue(s.unionAll)
+		}
+		enabled, _ := call.Argument(0).ToBoolean()
+		s.unionAll = enabled
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "bounds",
+		Signatures: []string{"(): {min: [number, number], max: [number, number]} | null"},
+		Doc: "Returns the corners of the smallest rectangle containing all of the\n" +
+			"lines drawn so far (not counting a line that the pen is still down\n" +
+			"for, or code written by echo()), or null if nothing has been drawn.",
+	}, func(call otto.FunctionCall) otto.Value {
+		if s.bounds.Empty {
+			return otto.NullValue()
+		}
+		return s.toJsValue(map[string]interface{}{
+			"min": []float64{s.bounds.Min[0] / s.unitScale, s.bounds.Min[1] / s.unitScale},
+			"max": []float64{s.bounds.Max[0] / s.unitScale, s.bounds.Max[1] / s.unitScale},
+		})
+	})
+	define(builtin{
+		Name:       "emit_bounds",
+		Signatures: []string{"(format: \"comment\" | \"module\" | false): void"},
+		Doc: "Writes the final bounding box of everything drawn at the end of the\n" +
+			"output, either as a comment or as a module called bounding_box()\n" +
+			"which draws it as a square.",
+	}, func(call otto.FunctionCall) otto.Value {
+		format := call.Argument(0)
+		enabled, _ := format.ToBoolean()
+		switch {
+		case format.IsBoolean() && !enabled:
+			s.boundsFormat = ""
+		case format.IsString() && (s.toString(format) == "comment" || s.toString(format) == "module"):
+			s.boundsFormat = s.toString(format)
+		default:
+			s.throwErrorf("Invalid bounds format: %s", format.String())
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "wrap",
+		Signatures: []string{"(wrapper: string, fn: () => void): void"},
+		Doc: "Wraps the OpenSCAD code generated by fn in a block, e.g.\n" +
+			"wrap('linear_extrude(height = 3)', fn).  scad() does the same with\n" +
+			"the module's parameters given as an object.",
+	}, func(call otto.FunctionCall) otto.Value {
+		wrapper := s.toString(call.Argument(0))
+		ignoreInPreview(wrapper)
+		outBeginBlock(wrapper)
+		s.callFunction(call.Argument(1))
+		outEndBlock()
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "scad",
+		Signatures: []string{"(name: string, params?: {[name: string]: any}, fn?: () => void): void"},
+		Doc: "Calls the OpenSCAD module with the given name and parameters, e.g.\n" +
+			"scad('linear_extrude', {height: 3, twist: 90}, fn), wrapping the\n" +
+			"OpenSCAD code generated by fn.  Without fn, the module is called on\n" +
+			"its own, e.g. scad('circle', {r: 2}).  Parameter values may be\n" +
+			"numbers, strings, booleans, arrays of these, or null for undef, and\n" +
+			"are written the same way as the rest of the output.  Lengths are not\n" +
+			"converted from the current units.",
+	}, func(call otto.FunctionCall) otto.Value {
+		name := s.toString(call.Argument(0))
+		if !identifierPattern.MatchString(name) {
+			s.throwErrorf("Invalid module name: %q", name)
+		}
+		var arguments []string
+		params := call.Argument(1)
+		if params.IsObject() {
+			for _, key := range params.Object().Keys() {
+				if !identifierPattern.MatchString(key) && !specialVariablePattern.MatchString(key) {
+					s.throwErrorf("Invalid parameter name for %s(): %q", name, key)
+				}
+				value := s.getProperty(params, key)
+				scadValue := "undef"
+				if !value.IsNull() && !value.IsUndefined() {
+					scadValue = s.toScadValue(value)
+				}
+				arguments = append(arguments, key+" = "+scadValue)
+			}
+		} else if params.IsDefined() && !params.IsNull() {
+			s.throwErrorf("Parameters for %s() must be an object", name)
+		}
+		statement := name + "(" + strings.Join(arguments, ", ") + ")"
+		if fn := call.Argument(2); fn.IsDefined() {
+			ignoreInPreview(statement)
+			outBeginBlock(statement)
+			s.callFunction(fn)
+			outEndBlock()
+		} else {
+			write(indent(s.indentLevel), statement, ";\n")
+		}
+		return otto.UndefinedValue()
+	})
+	// Wraps the OpenSCAD code generated by fn in a multmatrix() block
+	outMatrix := func(m [4][4]float64, fn otto.Value) {
+		rows := make([]string, 4)
+		for i, row := range m {
+			values := make([]string, 4)
+			for j, value := range row {
+				values[j] = s.formatFloat(value)
+			}
+			rows[i] = "[" + strings.Join(values, ", ") + "]"
+		}
+		outBeginBlock("multmatrix([" + strings.Join(rows, ", ") + "])")
+		transformed := 0
+		if s.opts.Preview != nil {
+			transformed = len(s.opts.Preview.Shapes)
+		}
+		s.callFunction(fn)
+		// The preview is seen from above, so only x and y matter
+		if s.opts.Preview != nil {
+			for _, shape := range s.opts.Preview.Shapes[transformed:] {
+				for i, point := range shape {
+					shape[i] = [2]float64{
+						m[0][0]*point[0] + m[0][1]*point[1] + m[0][3],
+						m[1][0]*point[0] + m[1][1]*point[1] + m[1][3],
+					}
+				}
+			}
+		}
+		outEndBlock()
+	}
+	define(builtin{
+		Name:       "matrix",
+		Signatures: []string{"(m: number[][], fn: () => void): void"},
+		Doc: "Transforms the OpenSCAD code generated by fn with a multmatrix()\n" +
+			"block.  m is a 4x4 matrix, or its first 3 rows.  The translation in\n" +
+			"the last column is in the current units.",
+	}, func(call otto.FunctionCall) otto.Value {
+		rows := call.Argument(0)
+		length := s.getProperty(rows, "length")
+		if !length.IsDefined() || (s.toInt(length) != 3 && s.toInt(length) != 4) {
+			s.throwError("Matrix must have 3 or 4 rows")
+		}
+		m := [4][4]float64{3: {0, 0, 0, 1}}
+		for i := 0; i < s.toInt(length); i++ {
+			row := s.getProperty(rows, strconv.Itoa(i))
+			if !row.IsObject() || s.toInt(s.getProperty(row, "length")) != 4 {
+				s.throwErrorf("Matrix row %d must have 4 columns", i)
+			}
+			for j := range m[i] {
+				m[i][j] = s.toFloat(s.getProperty(row, strconv.Itoa(j)))
+			}
+			if i < 3 {
+				m[i][3] *= s.unitScale
+			}
+		}
+		outMatrix(m, call.Argument(1))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "shear",
+		Signatures: []string{"(sx: number, sy: number, fn: () => void): void"},
+		Doc: "Shears the OpenSCAD code generated by fn, so that each point moves\n" +
+			"sx times its Y coordinate along the X axis, and sy times its X\n" +
+			"coordinate along the Y axis.",
+	}, func(call otto.FunctionCall) otto.Value {
+		sx := s.toFloat(call.Argument(0))
+		sy := s.toFloat(call.Argument(1))
+		outMatrix([4][4]float64{
+			{1, sx, 0, 0},
+			{sy, 1, 0, 0},
+			{0, 0, 1, 0},
+			{0, 0, 0, 1},
+		}, call.Argument(2))
+		return otto.UndefinedValue()
+	})
+	for _, modifier := range []struct {
+		name, prefix, doc string
+	}{
+		{"debug", "#", "highlighted in red in OpenSCAD's preview."},
+		{"background", "%", "shown transparent in OpenSCAD's preview, and\n" +
+			"left out of the rendered model."},
+		{"root", "!", "drawn by itself, ignoring the rest of the\n" +
+			"model."},
+		{"disable", "*", "left out of the model."},
+	} {
+		modifier := modifier
+		define(builtin{
+			Name:       modifier.name,
+			Signatures: []string{"(fn: () => void): void"},
+			Doc: "Wraps the OpenSCAD code generated by fn in a block with the " +
+				modifier.prefix + "\nmodifier, so that it is " + modifier.doc,
+		}, func(call otto.FunctionCall) otto.Value {
+			outBeginBlock(modifier.prefix + "union()")
+			s.callFunction(call.Argument(0))
+			outEndBlock()
+			return otto.UndefinedValue()
+		})
+	}
+	define(builtin{
+		Name:       "echo",
+		Signatures: []string{"(code: string): void"},
+		Doc:        "Writes OpenSCAD code directly to the output.",
+	}, func(call otto.FunctionCall) otto.Value {
+		outEcho(s.toString(call.Argument(0)))
+		return otto.UndefinedValue()
+	})
+	// outPlaced writes an OpenSCAD statement for an object which is moved to
+	// the turtle's position and rotated to its heading.
+	outPlaced := func(statement string) {
+		if s.recording != nil {
+			s.throwError("External geometry cannot be recorded")
+		}
+		write(indent(s.indentLevel),
+			"translate([", s.formatFloat(s.turtle.X), ", ", s.formatFloat(s.turtle.Y), ", 0]) ",
+			"rotate(", s.formatFloat(s.turtle.Heading), ") ",
+			statement, ";\n")
+	}
+	define(builtin{
+		Name:       "scad_import",
+		Signatures: []string{"(filename: string, options?: {convexity?: number}): void"},
+		Doc: "Writes an OpenSCAD import() statement for an STL, OFF, DXF or SVG\n" +
+			"file, moved to the turtle's position and rotated to its heading.\n" +
+			"The file is loaded by OpenSCAD relative to the output file.",
+	}, func(call otto.FunctionCall) otto.Value {
+		filename := s.toString(call.Argument(0))
+		arguments := []string{strconv.Quote(filename)}
+		if convexity := s.getProperty(call.Argument(1), "convexity"); convexity.IsDefined() {
+			arguments = append(arguments, "convexity = "+strconv.Itoa(s.toInt(convexity)))
+		}
+		outPlaced("import(" + strings.Join(arguments, ", ") + ")")
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "scad_surface",
+		Signatures: []string{"(filename: string, options?: {center?: boolean, invert?: boolean, convexity?: number}): void"},
+		Doc: "Writes an OpenSCAD surface() statement for a heightmap (a PNG image\n" +
+			"or a DAT file), moved to the turtle's position and rotated to its\n" +
+			"heading.  The file is loaded by OpenSCAD relative to the output file.",
+	}, func(call otto.FunctionCall) otto.Value {
+		filename := s.toString(call.Argument(0))
+		options := call.Argument(1)
+		arguments := []string{"file = " + strconv.Quote(filename)}
+		for _, key := range []string{"center", "invert"} {
+			if value := s.getProperty(options, key); value.IsDefined() {
+				if !value.IsBoolean() {
+					s.throwErrorf("Expected a boolean for %s", key)
+				}
+				arguments = append(arguments, key+" = "+s.toScadValue(value))
+			}
+		}
+		if convexity := s.getProperty(options, "convexity"); convexity.IsDefined() {
+			arguments = append(arguments, "convexity = "+strconv.Itoa(s.toInt(convexity)))
+		}
+		outPlaced("surface(" + strings.Join(arguments, ", ") + ")")
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "units",
+		Signatures: []string{"(): string", "(units: \"mm\" | \"cm\" | \"in\"): void"},
+		Doc: "Gets or sets the units used for lengths and positions given to and\n" +
+			"returned by other functions.  The output is always in millimeters.",
+	}, func(call otto.FunctionCall) otto.Value {
+		if call.Argument(0).IsUndefined() {
+			return s.toJsValue(s.units)
+		}
+		units := s.toString(call.Argument(0))
+		scale, ok := unitScales[units]
+		if !ok {
+			s.throwErrorf("Unknown units: %s", units)
+		}
+		s.units = units
+		s.unitScale = scale
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "inch",
+		Signatures: []string{"(inches: number): number"},
+		Doc:        "Converts a length in inches into the current units.",
+	}, func(call otto.FunctionCall) otto.Value {
+		return s.fromLength(s.toFloat(call.Argument(0)) * unitScales["in"])
+	})
+	define(builtin{
+		Name:       "mm",
+		Signatures: []string{"(millimeters: number): number"},
+		Doc:        "Converts a length in millimeters into the current units.",
+	}, func(call otto.FunctionCall) otto.Value {
+		return s.fromLength(s.toFloat(call.Argument(0)))
+	})
+	define(builtin{
+		Name:       "snap",
+		Signatures: []string{"(): number", "(grid: number): void"},
+		Doc: "Gets or sets the grid size that the coordinates of points in the\n" +
+			"output are rounded to, for example 0.01.  0 turns off rounding.",
+	}, func(call otto.FunctionCall) otto.Value {
+		if call.Argument(0).IsUndefined() {
+			return s.fromLength(s.snapGrid)
+		}
+		grid := s.toLength(call.Argument(0))
+		if grid < 0 {
+			s.throwError("Snap grid size set to less than 0")
+		}
+		s.snapGrid = grid
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "random",
+		Signatures: []string{"(): number", "(min: number, max: number): number"},
+		Doc: "Returns a random number between 0 (or min) and 1 (or max).  The\n" +
+			"numbers are the same every time the script runs, unless the seed is\n" +
+			"changed with seed() or --seed.  Math.random() returns the same numbers.",
+	}, func(call otto.FunctionCall) otto.Value {
+		n := s.random.Float64()
+		if !call.Argument(0).IsUndefined() {
+			min := s.toFloat(call.Argument(0))
+			max := s.toFloat(call.Argument(1))
+			n = min + n*(max-min)
+		}
+		return s.toJsValue(n)
+	})
+	define(builtin{
+		Name:       "seed",
+		Signatures: []string{"(seed: number): void"},
+		Doc:        "Sets the seed used to generate the numbers returned by random().",
+	}, func(call otto.FunctionCall) otto.Value {
+		s.random.Seed(int64(s.toInt(call.Argument(0))))
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "load_svg",
+		Signatures: []string{"(filename: string, curveSegments?: number): number[][][]"},
+		Doc: "Loads the outlines in an SVG file as lists of [x, y] points, one per\n" +
+			"subpath.  Curves are divided into curveSegments segments (default 16).",
+	}, func(call otto.FunctionCall) otto.Value {
+		filename := s.toString(call.Argument(0))
+		curveSegments := 16
+		if !call.Argument(1).IsUndefined() {
+			curveSegments = s.toInt(call.Argument(1))
+			if curveSegments < 1 {
+				s.throwErrorf("Invalid curve segments value: %d", curveSegments)
+			}
+		}
+		data := readDataFile(filename)
+		paths, err := parseSvgFile(bytes.NewReader(data), curveSegments)
+		if err != nil {
+			s.throwErrorf("Error parsing %s: %s", filename, err)
+		}
+		return s.toJsValue(paths)
+	})
+	define(builtin{
+		Name:       "load_points",
+		Signatures: []string{"(filename: string): number[][]"},
+		Doc:        "Loads a list of [x, y] points from the first two columns of a CSV file.",
+	}, func(call otto.FunctionCall) otto.Value {
+		filename := s.toString(call.Argument(0))
+		data := readDataFile(filename)
+		points, err := parseCsvPoints(bytes.NewReader(data))
+		if err != nil {
+			s.throwErrorf("Error parsing %s: %s", filename, err)
+		}
+		return s.toJsValue(points)
+	})
+	define(builtin{
+		Name:       "load_json",
+		Signatures: []string{"(filename: string): any"},
+		Doc:        "Loads and parses a JSON file.",
+	}, func(call otto.FunctionCall) otto.Value {
+		filename := s.toString(call.Argument(0))
+		data := readDataFile(filename)
+		value, err := vm.Call("JSON.parse", nil, string(data))
+		if err != nil {
+			s.throwErrorf("Error parsing %s: %s", filename, err)
+		}
+		return value
+	})
+	define(builtin{
+		Name:       "follow",
+		Signatures: []string{"(points: number[][]): void"},
+		Doc: "Moves the turtle to the first of the given [x, y] points without\n" +
+			"drawing, then through the rest of the points.",
+	}, func(call otto.FunctionCall) otto.Value {
+		points := s.toPoints(call.Argument(0))
+		for i, point := range points {
+			x, y := point[0]*s.unitScale, point[1]*s.unitScale
+			if i == 0 {
+				jumpTo(x, y)
+			} else {
+				moveTo(x, y)
+			}
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "draw",
+		Signatures: []string{"(points: number[][] | Path): void"},
+		Doc: "Draws a line through the given [x, y] points, or the lines in a path\n" +
+			"returned by record(), whether or not the pen is down.  Afterwards the\n" +
+			"turtle is at the end of the line, facing along its last segment, and\n" +
+			"the pen is up or down as it was before.",
+	}, func(call otto.FunctionCall) otto.Value {
+		keepPen(func() {
+			if pathValue := s.getProperty(call.Argument(0), "__path"); pathValue.IsDefined() {
+				exported, _ := pathValue.Export()
+				path, ok := exported.(*recordedPath)
+				if !ok {
+					s.throwError("Invalid path passed to draw()")
+				}
+				replayPath(path)
+			} else {
+				points := s.toPoints(call.Argument(0))
+				penUp()
+				for i, point := range points {
+					x, y := point[0]*s.unitScale, point[1]*s.unitScale
+					if i == 0 {
+						jumpTo(x, y)
+						penDown()
+					} else {
+						if x != s.turtle.X || y != s.turtle.Y {
+							s.turtle.Heading = radToDeg(math.Atan2(y-s.turtle.Y, x-s.turtle.X))
+						}
+						moveTo(x, y)
+					}
+				}
+				penUp()
+			}
+		})
+		return otto.UndefinedValue()
+	})
+
+	define(builtin{
+		Name:       "loft",
+		Signatures: []string{"(pathA: Path, pathB: Path): void", "(pathA: Path, pathB: Path, height: number): void"},
+		Doc: "Fills the space between two paths returned by record(), which must\n" +
+			"have the same number of points, joining each point of pathA to the\n" +
+			"point in the same place in pathB.  Without a height, a polygon is\n" +
+			"written between the paths.  With a height, the paths are closed\n" +
+			"outlines, and a solid is written with pathA at the bottom and pathB\n" +
+			"this far above it, such as a duct from a rectangle to a circle.",
+	}, func(call otto.FunctionCall) otto.Value {
+		pathPoints := func(value otto.Value) [][2]float64 {
+			exported, _ := s.getProperty(value, "__path").Export()
+			path, ok := exported.(*recordedPath)
+			if !ok {
+				s.throwError("loft() needs two paths returned by record()")
+			}
+			var points [][2]float64
+			for _, stroke := range path.Strokes {
+				for _, point := range stroke {
+					points = append(points, [2]float64{point.X, point.Y})
+				}
+			}
+			return points
+		}
+		reversed := func(points [][2]float64) [][2]float64 {
+			result := make([][2]float64, len(points))
+			for i, point := range points {
+				result[len(points)-1-i] = point
+			}
+			return result
+		}
+		a, b := pathPoints(call.Argument(0)), pathPoints(call.Argument(1))
+		solid := call.Argument(2).IsDefined()
+		if solid {
+			// Outlines which end where they started don't need the last point
+			for _, points := range []*[][2]float64{&a, &b} {
+				if n := len(*points); n > 1 && (*points)[0] == (*points)[n-1] {
+					*points = (*points)[:n-1]
+				}
+			}
+		}
+		if len(a) != len(b) {
+			s.throwErrorf("Paths passed to loft() have different numbers of points: %d and %d", len(a), len(b))
+		}
+		if len(a) < 2 || (solid && len(a) < 3) {
+			s.throwError("Paths passed to loft() have too few points")
+		}
+
+		s.outline = s.outline[:0]
+		if solid {
+			height := s.toLength(call.Argument(2))
+			if height <= 0 {
+				s.throwError("loft() height must be greater than 0")
+			}
+			if signedArea(a) > 0 {
+				a, b = reversed(a), reversed(b)
+			}
+			bottom := make([][3]float64, len(a))
+			top := make([][3]float64, len(b))
+			for i := range a {
+				bottom[i] = [3]float64{a[i][0], a[i][1], 0}
+				top[i] = [3]float64{b[i][0], b[i][1], height}
+			}
+			outPolyhedron(bottom, top)
+		} else {
+			// Go along one path and back along the other, clockwise
+			runs := [][][2]float64{a, reversed(b)}
+			if signedArea(append(append([][2]float64(nil), a...), runs[1]...)) > 0 {
+				runs = [][][2]float64{b, reversed(a)}
+			}
+			outBeginPolygon()
+			for i, run := range runs {
+				if i > 0 {
+					outNewLine()
+				}
+				for j, point := range run {
+					outPoint(point[0], point[1], j == len(run)-1)
+				}
+			}
+			outEndPolygon()
+		}
+		for _, point := range s.outline {
+			s.bounds.add(point[0], point[1])
+		}
+		return otto.UndefinedValue()
+	})
+	define(builtin{
+		Name:       "deffunction",
+		Signatures: []string{"<T extends (...args: any[]) => any>(name: string, fn: T): T"},
+		Doc: "Writes an OpenSCAD function with the given name which does the same\n" +
+			"as fn, so that values computed from parameters stay editable in the\n" +
+			"output, and returns fn.  fn may only declare variables with var,\n" +
+			"return values (using if statements to choose between them), and use\n" +
+			"arithmetic, comparisons, arrays, Math functions, variables declared\n" +
+			"with param(), and other functions defined with deffunction().\n" +
+			"Values are not converted between units.",
+	}, func(call otto.FunctionCall) otto.Value {
+		name := s.toString(call.Argument(0))
+		if !identifierPattern.MatchString(name) {
+			s.throwErrorf("Invalid function name: %q", name)
+		}
+		if s.functionNames[name] {
+			s.throwErrorf("Function already defined: %s", name)
+		}
+		fn := call.Argument(1)
+		if !fn.IsFunction() {
+			s.throwErrorf("Non-function value passed to deffunction() for %s", name)
+		}
+		if strings.Contains(fn.String(), "[native code]") {
+			s.throwErrorf("Built-in function passed to deffunction() for %s", name)
+		}
+		params, body, err := translateFunction(name, fn.String(),
+			func(variable string) bool { return s.declared[variable] },
+			func(function string) bool { return s.functionNames[function] })
+		if err != nil {
+			s.throwErrorf("Invalid function passed to deffunction() for %s: %s", name, err)
+		}
+		s.functionNames[name] = true
+		s.functions = append(s.functions,
+			"function "+name+"("+strings.Join(params, ", ")+") = "+body+";")
+		return fn
+	})
+	define(builtin{
+		Name:       "register",
+		Signatures: []string{"(name: string, fn: (...args: any[]) => any, options?: {signature?: string, doc?: string}): void"},
+		Doc: "Adds a command with the given name which calls fn, usually from a\n" +
+			"plugin loaded with --plugin.  A command can't replace a built-in\n" +
+			"function or another command.  options may give the command's\n" +
+			"TypeScript signature, e.g. \"(teeth: number): void\", and its\n" +
+			"documentation for --emit-dts.",
+	}, func(call otto.FunctionCall) otto.Value {
+		name := s.toString(call.Argument(0))
+		if !identifierPattern.MatchString(name) {
+			s.throwErrorf("Invalid command name: %q", name)
+		}
+		fn := call.Argument(1)
+		if !fn.IsFunction() {
+			s.throwErrorf("Non-function value passed to register() for %s", name)
+		}
+		for _, b := range s.builtins {
+			if b.Name == name {
+				s.throwErrorf("Command already defined: %s", name)
+			}
+			for _, alias := range b.Aliases {
+				if alias == name {
+					s.throwErrorf("Command already defined: %s", name)
+				}
+			}
+		}
+
+		b := builtin{
+			Name:       name,
+			Signatures: []string{"(...args: any[]): any"},
+			Doc:        "Registered by a plugin.",
+		}
+		options := call.Argument(2)
+		if signature := s.getProperty(options, "signature"); signature.IsDefined() {
+			b.Signatures = []string{s.toString(signature)}
+		}
+		if doc := s.getProperty(options, "doc"); doc.IsDefined() {
+			b.Doc = s.toString(doc)
+		}
+		define(b, func(call otto.FunctionCall) otto.Value {
+			args := make([]interface{}, len(call.ArgumentList))
+			for i, arg := range call.ArgumentList {
+				args[i] = arg
+			}
+			return s.callFunction(fn, args...)
+		})
+		return otto.UndefinedValue()
+	})
+
+	// Make Math.random() repeatable too
+	mathObject, _ := vm.Get("Math")
+	mathObject.Object().Set("random", func(call otto.FunctionCall) otto.Value {
+		return s.toJsValue(s.random.Float64())
+	})
+
+	return s
+}
+
+// runPreludes runs the plugins and then the init scripts given in the
+// script's options, and sets the global variables given in its Defines.  It
+// is called once before running the script, or all of the REPL's commands.
+func (s *script) runPreludes() error {
+	if s.opts.Profile != nil {
+		defer s.startProfiling()()
+	}
+	for _, prelude := range append(s.opts.Plugins, s.opts.Init...) {
+		program, err := s.vm.Compile(prelude.Name, prelude.Source)
+		if err == nil {
+			_, err = s.vm.Run(program)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Set the variables given in the script's options, once for all of the
+	// code run afterwards
+	for name, value := range s.opts.Defines {
+		if !identifierPattern.MatchString(name) {
+			return fmt.Errorf("Invalid variable name: %q", name)
+		}
+		if err := s.vm.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startProfiling samples the running script for its profile until the
+// returned function is called.
+func (s *script) startProfiling() func() {
+	if s.vm.Interrupt == nil {
+		s.vm.Interrupt = make(chan func(), 1)
+	}
+	return s.opts.Profile.startSampling(s.vm)
+}
+
+// run executes JavaScript code in the context of the script and returns the
+// value of its last statement.  It may be called more than once to run
+// additional code.
+func (s *script) run(jsInput string) (value otto.Value, err error) {
+	// Strip hashbang line if present
+	jsInput = regexp.MustCompile(`^#!.*\n`).ReplaceAllString(jsInput, "\n")
+
+	vm := s.vm
+
+	// Limit the running time of the script if requested
+	if s.opts.Timeout > 0 {
+		interrupt := make(chan func(), 1) // The buffer prevents blocking
+		vm.Interrupt = interrupt
+		timer := time.AfterFunc(s.opts.Timeout, func() {
+			interrupt <- func() {
+				panic(errTimeout)
+			}
+		})
+		defer timer.Stop()
+		defer func() {
+			if caught := recover(); caught != nil {
+				if caught != errTimeout {
+					panic(caught)
+				}
+				err = errTimeout
+			}
+		}()
+	}
+
+	if s.opts.Profile != nil {
+		defer s.startProfiling()()
+	}
+
+	// Run the script
+	return vm.Run(jsInput)
+}
+
+// writeOutput writes all of the OpenSCAD code generated by the script, once
+// the script has finished, through a single buffered writer.  The body is
+// copied from s.body, a line at a time if it needs to be indented inside a
+// block.
+func (s *script) writeOutput(w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	out := &outputWriter{w: buffered}
+	if s.usesBOSL2 {
+		out.write("include <BOSL2/std.scad>\n\n")
+	}
+	for _, declaration := range s.declarations {
+		out.write(declaration, "\n")
+	}
+	if len(s.declarations) > 0 {
+		out.write("\n")
+	}
+
+	// Move and scale the drawing as requested, and union its shapes if
+	// asked to.  The bounding box is transformed too, so that it still
+	// matches the drawing.
+	body := s.body.String()
+	bounds := s.bounds
+	scale, offset := s.outputTransform()
+	indent := ""
+	transformed := scale != 1 || offset != [2]float64{}
+	if transformed {
+		out.write("translate([", s.formatFloat(offset[0]), ", ", s.formatFloat(offset[1]), "]) ")
+		if scale != 1 {
+			out.write("scale(", s.formatFloat(scale), ") ")
+		}
+		out.write("{\n")
+		indent = s.opts.Indent
+		for i := range bounds.Min {
+			bounds.Min[i] = bounds.Min[i]*scale + offset[i]
+			bounds.Max[i] = bounds.Max[i]*scale + offset[i]
+		}
+	}
+	union := s.unionAll && body != ""
+	if union {
+		out.write(indent, "union() {\n")
+		out.writeIndented(body, indent+s.opts.Indent)
+		out.write(indent, "}\n")
+	} else {
+		out.writeIndented(body, indent)
+	}
+	if transformed {
+		out.write("}\n")
+	}
+
+	// Anchors are hidden from the Customizer, and moved along with the
+	// drawing
+	if len(s.anchors) > 0 {
+		out.write("\n/* [Hidden] */\n// Anchors: [x, y, heading in degrees]\n")
+		for _, anchor := range s.anchors {
+			out.write("anchor_", anchor.Name, " = [",
+				s.formatFloat(anchor.X*scale+offset[0]), ", ",
+				s.formatFloat(anchor.Y*scale+offset[1]), ", ",
+				s.formatFloat(anchor.Heading), "];\n")
+		}
+	}
+	for i, function := range s.functions {
+		if i == 0 {
+			out.write("\n")
+		}
+		out.write(function, "\n")
+	}
+	for _, module := range s.modules {
+		out.write("\nmodule ", module.Name, "() {\n", module.Body.String(), "}\n")
+	}
+	if s.boundsFormat != "" && !bounds.Empty {
+		min := "[" + s.formatFloat(bounds.Min[0]) + ", " + s.formatFloat(bounds.Min[1]) + "]"
+		max := "[" + s.formatFloat(bounds.Max[0]) + ", " + s.formatFloat(bounds.Max[1]) + "]"
+		size := "[" + s.formatFloat(bounds.Max[0]-bounds.Min[0]) + ", " +
+			s.formatFloat(bounds.Max[1]-bounds.Min[1]) + "]"
+		if s.boundsFormat == "module" {
+			out.write("\nmodule bounding_box() {\n", s.opts.Indent, "translate(", min, ") square(", size, ");\n}\n")
+		} else {
+			out.write("\n// Bounding box: ", min, " to ", max, "\n")
+		}
+	}
+
+	if out.err != nil {
+		return out.err
+	}
+	return buffered.Flush()
+}
+
+// outputWriter writes strings to w, keeping the first error so that it only
+// needs to be checked once at the end.
+type outputWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (o *outputWriter) write(strs ...string) {
+	for _, str := range strs {
+		if o.err != nil {
+			return
+		}
+		_, o.err = io.WriteString(o.w, str)
+	}
+}
+
+// writeIndented writes code with indent at the start of each line that
+// isn't empty.
+func (o *outputWriter) writeIndented(code string, indent string) {
+	if indent == "" {
+		o.write(code)
+		return
+	}
+	for code != "" {
+		end := strings.IndexByte(code, '\n') + 1
+		if end == 0 {
+			end = len(code)
+		}
+		if end > 1 {
+			o.write(indent)
+		}
+		o.write(code[:end])
+		code = code[end:]
+	}
+}
+
+// outputTransform returns the scale factor and then the offset which are
+// applied to the drawing to center it or fit it into the size given in the
+// script's options.
+func (s *script) outputTransform() (float64, [2]float64) {
+	scale := 1.0
+	offset := [2]float64{}
+	if s.bounds.Empty {
+		return scale, offset
+	}
+	size := [2]float64{
+		s.bounds.Max[0] - s.bounds.Min[0],
+		s.bounds.Max[1] - s.bounds.Min[1],
+	}
+	if s.opts.Fit != [2]float64{} {
+		scale = math.Inf(1)
+		for i := range size {
+			if size[i] > 0 {
+				scale = math.Min(scale, s.opts.Fit[i]/size[i])
+			}
+		}
+		if math.IsInf(scale, 1) {
+			scale = 1
+		}
+		if !s.opts.Center {
+			// Put the drawing's lower left corner at the origin
+			for i := range offset {
+				offset[i] = -s.bounds.Min[i] * scale
+			}
+		}
+	}
+	if s.opts.Center {
+		for i := range offset {
+			offset[i] = -(s.bounds.Min[i] + s.bounds.Max[i]) / 2 * scale
+		}
+	}
+	return scale, offset
+}
+
+// formatError describes an error returned by jsToScad, including the
+// JavaScript stack trace if there is one.
+func formatError(err error) string {
+	if jsErr, ok := err.(*otto.Error); ok {
+		return "JavaScript error: " + jsErr.String()
+	}
+	return "JavaScript error: " + err.Error()
+}
+
+// diagnostic describes an error or warning at a location in a script.  Line
+// and Column are 0 if the location is unknown.
+type diagnostic struct {
+	// File is the name of the plugin where the problem is, or empty if it
+	// is in the script itself.
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d diagnostic) String() string {
+	if d.Line == 0 {
+		return d.Message
+	}
+	return fmt.Sprintf("line %d: %s", d.Line, d.Message)
+}
+
+// scriptLocationPattern matches the location of a frame of the script or a
+// plugin in the stack trace of a JavaScript error.
+var scriptLocationPattern = regexp.MustCompile(`(?m)^\s*at ([^\s()]+):(\d+):(\d+)$`)
+
+// scriptFilename returns the file name to report in diagnostics for a
+// location in JavaScript code with the given file name.
+func scriptFilename(name string) string {
+	if name == "<anonymous>" {
+		return ""
+	}
+	return name
+}
+
+// errorDiagnostics describes an error returned by a script, including the
+// location where it happened if possible.  Syntax errors may give more than
+// one diagnostic.
+func errorDiagnostics(err error) []diagnostic {
+	switch err := err.(type) {
+	case parser.ErrorList:
+		var diagnostics []diagnostic
+		for _, syntaxErr := range err {
+			d := diagnostic{
+				File:    scriptFilename(syntaxErr.Position.Filename),
+				Line:    syntaxErr.Position.Line,
+				Column:  syntaxErr.Position.Column,
+				Message: "SyntaxError: " + syntaxErr.Message,
+			}
+			// The parser can report the same error more than once
+			if len(diagnostics) == 0 || diagnostics[len(diagnostics)-1] != d {
+				diagnostics = append(diagnostics, d)
+			}
+		}
+		return diagnostics
+	case *otto.Error:
+		d := diagnostic{Message: err.Error()}
+		if match := scriptLocationPattern.FindStringSubmatch(err.String()); match != nil {
+			d.File = scriptFilename(match[1])
+			d.Line, _ = strconv.Atoi(match[2])
+			d.Column, _ = strconv.Atoi(match[3])
+		}
+		return []diagnostic{d}
+	}
+	return []diagnostic{{Message: err.Error()}}
+}
@@ -0,0 +1,85 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// configFilenames lists the names of project config files, in the order
+// they are looked for in each directory.
+var configFilenames = []string{"goscad.json", ".goscadrc"}
+
+// projectConfig holds default settings for the command line, read from a
+// project config file.  Command-line flags take precedence over it.
+type projectConfig struct {
+	Precision  *int   `json:"precision"`
+	Indent     int    `json:"indent"`
+	OutputDir  string `json:"output-dir"`
+	Strict     bool   `json:"strict"`
+	StrictFail bool   `json:"strict-fail"`
+	// Defines gives the values of global variables set before scripts run.
+	Defines map[string]interface{} `json:"defines"`
+	// Plugins and Init list JavaScript files which are run before each
+	// script, as for --plugin and --init.
+	Plugins []string `json:"plugins"`
+	Init    []string `json:"init"`
+}
+
+// findConfig looks for a project config file in dir and each of its parent
+// directories, and returns the path of the first one found, or an empty
+// string if there isn't one.
+func findConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range configFilenames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			} else if !os.IsNotExist(err) {
+				return "", err
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads a project config file, which holds a JSON object.  A
+// relative output directory or script path is resolved relative to the
+// file.
+func loadConfig(path string) (projectConfig, error) {
+	var config projectConfig
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("%s: %s", path, err)
+	}
+	if config.OutputDir != "" && !filepath.IsAbs(config.OutputDir) {
+		config.OutputDir = filepath.Join(filepath.Dir(path), config.OutputDir)
+	}
+	for _, paths := range [][]string{config.Plugins, config.Init} {
+		for i, script := range paths {
+			if !filepath.IsAbs(script) {
+				paths[i] = filepath.Join(filepath.Dir(path), script)
+			}
+		}
+	}
+	return config, nil
+}
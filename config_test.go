@@ -0,0 +1,85 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"goscad.json": `{"precision": 3, "indent": 2, "output-dir": "build", "strict": true,
+			"defines": {"width": 40}, "plugins": ["lib/a.js", "/abs/b.js"], "init": ["c.js"]}`,
+		"sub/dir/.keep":   "",
+		"other/.goscadrc": `{"strict-fail": true}`,
+		"bad/goscad.json": `{"strict": true, "unknown": 1}`,
+	})
+
+	path, err := findConfig(filepath.Join(dir, "sub", "dir"))
+	if err != nil || path != filepath.Join(dir, "goscad.json") {
+		t.Fatalf("findConfig returned %q, %v", path, err)
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	precision := 3
+	expected := projectConfig{
+		Precision: &precision,
+		Indent:    2,
+		OutputDir: filepath.Join(dir, "build"),
+		Strict:    true,
+		Defines:   map[string]interface{}{"width": 40.0},
+		Plugins:   []string{filepath.Join(dir, "lib", "a.js"), "/abs/b.js"},
+		Init:      []string{filepath.Join(dir, "c.js")},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("loadConfig returned %+v, expected %+v", config, expected)
+	}
+
+	if path, err := findConfig(filepath.Join(dir, "other")); err != nil || path != filepath.Join(dir, "other", ".goscadrc") {
+		t.Errorf("findConfig found %q, %v", path, err)
+	}
+	if _, err := loadConfig(filepath.Join(dir, "bad", "goscad.json")); err == nil || !strings.Contains(err.Error(), `unknown field "unknown"`) {
+		t.Errorf("loadConfig with an unknown field returned %v", err)
+	}
+	if _, err := loadConfig(filepath.Join(dir, "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("loadConfig of a missing file returned %v", err)
+	}
+}
+
+func TestConfigAndFlags(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"goscad.json": `{"precision": 1, "indent": 2, "strict": true, "defines": {"length": 2.25, "name": "a"}}`,
+		"line.js":     "echo('// ' + name); end_cap_sides(4); pendown(); forward(0); forward(length); penup();",
+	})
+	warning := "line.js: warning: line 1: Zero-length forward move\n"
+	tests := []struct {
+		args []string
+		// The output starts with the name echoed by the script, and the
+		// line's points are indented by indent, and end at x = end
+		name, indent, end string
+		stderr            string
+		status            int
+	}{
+		{nil, "a", "  ", "2.2", warning, 0},
+		{[]string{"--strict=false", "--indent", "0", "--precision", "2", "-D", "name=b"}, "b", "\t", "2.25", "", 0},
+		{[]string{"--strict-fail"}, "a", "  ", "2.2", warning + "1 warning(s) found\n", 1},
+		{[]string{"--strict=false", "--strict-fail"}, "a", "  ", "2.2", warning + "1 warning(s) found\n", 1},
+		{[]string{"--no-config", "-D", "name=c", "-D", "length=3"}, "c", "\t", "3", "", 0},
+	}
+	for _, test := range tests {
+		result := runCommand(t, dir, "", append(test.args, "line.js")...)
+		start := "// " + test.name + "\npolygon(points = [\n" + test.indent + "[0,-0.5],"
+		if result.status != test.status || result.stderr != test.stderr ||
+			!strings.HasPrefix(result.stdout, start) || !strings.Contains(result.stdout, "["+test.end+",0.5]") {
+			t.Errorf("%v: exit status %d, stdout:\n%s\nstderr:\n%s\nexpected %d, %q and %q",
+				test.args, result.status, result.stdout, result.stderr, test.status, start, test.stderr)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseCsvPoints reads a list of points from CSV data, using the first two
+// columns of each row as the X and Y coordinates.  Blank lines and lines
+// starting with '#' are skipped, as is the first row if its first two
+// columns are not numbers (a header row).  Any other row that does not start
+// with two numbers is an error.
+func parseCsvPoints(r io.Reader) ([][2]float64, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var points [][2]float64
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("expected at least 2 columns: %q",
+				strings.Join(record, ","))
+		}
+		x, errX := strconv.ParseFloat(strings.TrimSpace(record[0]), 64)
+		y, errY := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if errX != nil || errY != nil {
+			if first {
+				continue
+			}
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: expected numbers in the first 2 columns: %q",
+				line, strings.Join(record, ","))
+		}
+		points = append(points, [2]float64{x, y})
+	}
+	return points, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// builtin describes a function which is available to go-scad scripts.
+type builtin struct {
+	Name string
+	// Signatures lists the TypeScript parameters and return type of each way
+	// that the function can be called, e.g. "(size: number): void".
+	Signatures []string
+	Doc        string
+	Aliases    []string
+}
+
+// dtsTypes declares the types of objects returned by go-scad functions.
+const dtsTypes = `
+/**
+ * Lines recorded by record().
+ */
+interface Path {
+	replay(): void;
+	translate(dx: number, dy: number): Path;
+	rotate(angle: number): Path;
+	scale(s: number): Path;
+	scale(sx: number, sy: number): Path;
+	reverse(): Path;
+	strokes(): number[][][];
+}
+`
+
+// writeDts writes TypeScript definitions for the given functions, so that
+// editors can provide autocompletion and type checking for go-scad scripts.
+func writeDts(w io.Writer, builtins []builtin) error {
+	var out strings.Builder
+	out.WriteString("// TypeScript definitions for the go-scad library.\n" +
+		"// Generated by `go-scad --emit-dts`; do not edit.\n")
+	out.WriteString(dtsTypes)
+	for _, b := range builtins {
+		out.WriteString("\n/**\n")
+		for _, line := range strings.Split(b.Doc, "\n") {
+			out.WriteString(strings.TrimRight(" * "+line, " ") + "\n")
+		}
+		out.WriteString(" */\n")
+		for _, signature := range b.Signatures {
+			fmt.Fprintf(&out, "declare function %s%s;\n", b.Name, signature)
+		}
+		for _, alias := range b.Aliases {
+			fmt.Fprintf(&out, "declare const %s: typeof %s;\n", alias, b.Name)
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// The definitions generated from the builtins should match the golden file,
+// so that changes to them are seen in review.
+func TestDts(t *testing.T) {
+	var actual strings.Builder
+	if err := writeDts(&actual, newScript(compileOptions{}).builtins); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("test", "go-scad.d.ts")
+	if os.Getenv("REGENERATE_OUTPUT") != "" {
+		if err := ioutil.WriteFile(path, []byte(actual.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.String() != string(expected) {
+		dmp := diffmatchpatch.New()
+		t.Error("output doesn't match " + path + ":\n" +
+			"\x1b[31m- actual\x1b[0m \x1b[32m+ expected\x1b[0m\n" +
+			dmp.DiffPrettyText(dmp.DiffMain(actual.String(), string(expected), false)))
+	}
+}
@@ -0,0 +1,93 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFit(t *testing.T) {
+	valid := map[string][2]float64{
+		"100x50":   {100, 50},
+		"2.5x0.25": {2.5, 0.25},
+		"1e2x3":    {100, 3},
+	}
+	for value, expected := range valid {
+		if fit, err := parseFit(value); err != nil || fit != expected {
+			t.Errorf("parseFit(%q) = %v, %v, expected %v", value, fit, err, expected)
+		}
+	}
+	for _, value := range []string{
+		"10x10junk", "10x10x10", "10", "x10", "10x", "0x10", "10x-5", "10 x 10", "NaNx1", "Infx1", "10X10",
+	} {
+		if fit, err := parseFit(value); err == nil {
+			t.Errorf("parseFit(%q) = %v, expected an error", value, fit)
+		}
+	}
+}
+
+func TestCenterAndFit(t *testing.T) {
+	script := "emit_bounds('comment'); end_cap_sides(4); pendown(); forward(9); penup();"
+	// The line, indented inside the given number of blocks
+	line := func(depth int) string {
+		indent := strings.Repeat("\t", depth)
+		return indent + "polygon(points = [\n" +
+			indent + "\t[0,-0.5], [-0.5,0], [0,0.5],\n" +
+			indent + "\t[9,0.5], [9.5,0], [9,-0.5],\n" +
+			indent + "]);\n"
+	}
+	tests := []struct {
+		name   string
+		opts   compileOptions
+		output string
+	}{{
+		"center",
+		compileOptions{Center: true},
+		"translate([-4.5, 0]) {\n" + line(1) + "}\n" +
+			"\n// Bounding box: [-5, -0.5] to [5, 0.5]\n",
+	}, {
+		"fit",
+		compileOptions{Fit: [2]float64{20, 20}},
+		"translate([1, 1]) scale(2) {\n" + line(1) + "}\n" +
+			"\n// Bounding box: [0, 0] to [20, 2]\n",
+	}, {
+		"center and fit",
+		compileOptions{Center: true, Fit: [2]float64{5, 5}},
+		"translate([-2.25, 0]) scale(0.5) {\n" + line(1) + "}\n" +
+			"\n// Bounding box: [-2.5, -0.25] to [2.5, 0.25]\n",
+	}, {
+		"center and union",
+		compileOptions{Center: true, UnionAll: true},
+		"translate([-4.5, 0]) {\n\tunion() {\n" + line(2) + "\t}\n}\n" +
+			"\n// Bounding box: [-5, -0.5] to [5, 0.5]\n",
+	}}
+	for _, test := range tests {
+		output, err := jsToScad(script, test.opts)
+		if err != nil {
+			t.Fatal(formatError(err))
+		}
+		if output != test.output {
+			t.Errorf("%s:\n%s\nexpected:\n%s", test.name, output, test.output)
+		}
+	}
+
+	// Empty drawings are left alone
+	output, err := jsToScad("emit_bounds('comment'); forward(1);", compileOptions{Center: true, Fit: [2]float64{5, 5}})
+	if err != nil || output != "" {
+		t.Errorf("empty drawing: %q, %v", output, err)
+	}
+}
+
+func TestFitFlag(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"line.js": "pendown(); forward(9); penup();"})
+	result := runCommand(t, dir, "", "--fit", "10x10junk", "line.js")
+	if result.status == 0 || !strings.Contains(result.stderr, "--fit must be given as WIDTHxHEIGHT") {
+		t.Errorf("exit status %d, stderr %q", result.status, result.stderr)
+	}
+	result = runCommand(t, dir, "", "--fit", "20x20", "line.js")
+	if result.status != 0 || !strings.HasPrefix(result.stdout, "translate([1, 1]) scale(2) {\n") {
+		t.Errorf("exit status %d, stdout %q", result.status, result.stdout)
+	}
+}
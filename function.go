@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/parser"
+	"github.com/robertkrimen/otto/token"
+)
+
+// scadOperators maps the JavaScript operators that can be translated into
+// OpenSCAD expressions to their OpenSCAD equivalents.
+var scadOperators = map[token.Token]string{
+	token.PLUS:             "+",
+	token.MINUS:            "-",
+	token.MULTIPLY:         "*",
+	token.SLASH:            "/",
+	token.REMAINDER:        "%",
+	token.LESS:             "<",
+	token.LESS_OR_EQUAL:    "<=",
+	token.GREATER:          ">",
+	token.GREATER_OR_EQUAL: ">=",
+	token.EQUAL:            "==",
+	token.STRICT_EQUAL:     "==",
+	token.NOT_EQUAL:        "!=",
+	token.STRICT_NOT_EQUAL: "!=",
+	token.LOGICAL_AND:      "&&",
+	token.LOGICAL_OR:       "||",
+}
+
+// scadMathFunctions maps the functions of the JavaScript Math object to
+// OpenSCAD expressions, where %s is replaced with the arguments.  OpenSCAD's
+// trigonometric functions use degrees instead of radians.  Arguments which
+// are binary expressions are already wrapped in parentheses.
+var scadMathFunctions = map[string]string{
+	"abs":   "abs(%s)",
+	"ceil":  "ceil(%s)",
+	"floor": "floor(%s)",
+	"round": "round(%s)",
+	"sqrt":  "sqrt(%s)",
+	"exp":   "exp(%s)",
+	"log":   "ln(%s)",
+	"pow":   "pow(%s)",
+	"min":   "min(%s)",
+	"max":   "max(%s)",
+	"sin":   "sin(%s * 180 / PI)",
+	"cos":   "cos(%s * 180 / PI)",
+	"tan":   "tan(%s * 180 / PI)",
+	"asin":  "(asin(%s) * PI / 180)",
+	"acos":  "(acos(%s) * PI / 180)",
+	"atan":  "(atan(%s) * PI / 180)",
+	"atan2": "(atan2(%s) * PI / 180)",
+}
+
+// functionTranslator converts a JavaScript function into an OpenSCAD
+// function.  Only a small subset of JavaScript is supported: the function's
+// body may declare variables with var and choose what to return with if
+// statements, and expressions may use arithmetic, comparisons, logical
+// operators, the conditional operator, arrays, Math functions, and calls to
+// OpenSCAD functions.
+type functionTranslator struct {
+	// source is the code being translated, used in error messages.
+	source string
+	// isVariable and isFunction report whether names declared outside the
+	// function are OpenSCAD variables or functions.
+	isVariable func(name string) bool
+	isFunction func(name string) bool
+	// locals holds the names of the function's parameters and variables.
+	locals map[string]bool
+}
+
+// translateFunction converts the source of a JavaScript function into the
+// parameter names and body of an OpenSCAD function with the given name.
+func translateFunction(name, source string, isVariable, isFunction func(name string) bool) ([]string, string, error) {
+	// Parse the function as an expression, so that it doesn't need a name
+	source = "(" + source + ")"
+	program, err := parser.ParseFile(nil, "", source, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	var fn *ast.FunctionLiteral
+	if len(program.Body) == 1 {
+		if statement, ok := program.Body[0].(*ast.ExpressionStatement); ok {
+			fn, _ = statement.Expression.(*ast.FunctionLiteral)
+		}
+	}
+	if fn == nil {
+		return nil, "", errors.New("not a function")
+	}
+
+	t := functionTranslator{
+		source:     source,
+		isVariable: isVariable,
+		isFunction: func(other string) bool { return other == name || isFunction(other) },
+		locals:     map[string]bool{},
+	}
+	var params []string
+	for _, param := range fn.ParameterList.List {
+		params = append(params, param.Name)
+		t.locals[param.Name] = true
+	}
+	body, ok := fn.Body.(*ast.BlockStatement)
+	if !ok {
+		return nil, "", t.unsupported(fn.Body)
+	}
+	result, err := t.statements(body.List)
+	return params, result, err
+}
+
+// unsupported returns an error describing code which can't be translated.
+func (t *functionTranslator) unsupported(node ast.Node) error {
+	if statement, ok := node.(*ast.ExpressionStatement); ok {
+		node = statement.Expression
+	}
+	start, end := int(node.Idx0())-1, int(node.Idx1())-1
+	if unary, ok := node.(*ast.UnaryExpression); ok && unary.Postfix {
+		start = int(unary.Operand.Idx0()) - 1
+	}
+	// The parser doesn't record where some statements start and end
+	if start < 0 || start >= len(t.source) {
+		return errors.New("can't translate the function into OpenSCAD")
+	}
+	if end <= start || end > len(t.source) {
+		end = len(t.source)
+	}
+	code := t.source[start:end]
+	if i := strings.IndexByte(code, '\n'); i >= 0 {
+		code = code[:i] + " ..."
+	}
+	return fmt.Errorf("can't translate %q into OpenSCAD", code)
+}
+
+// statements translates a list of statements which ends by returning a
+// value.
+func (t *functionTranslator) statements(list []ast.Statement) (string, error) {
+	for len(list) > 0 {
+		if _, ok := list[0].(*ast.EmptyStatement); !ok {
+			break
+		}
+		list = list[1:]
+	}
+	if len(list) == 0 {
+		return "", errors.New("function doesn't return a value")
+	}
+
+	switch statement := list[0].(type) {
+	case *ast.ReturnStatement:
+		if statement.Argument == nil {
+			return "", t.unsupported(statement)
+		}
+		return t.expression(statement.Argument)
+	case *ast.BlockStatement:
+		return t.statements(append(append([]ast.Statement(nil), statement.List...), list[1:]...))
+	case *ast.VariableStatement:
+		var assignments []string
+		for _, expression := range statement.List {
+			variable := expression.(*ast.VariableExpression)
+			if variable.Initializer == nil {
+				return "", t.unsupported(statement)
+			}
+			value, err := t.expression(variable.Initializer)
+			if err != nil {
+				return "", err
+			}
+			assignments = append(assignments, variable.Name+" = "+value)
+			t.locals[variable.Name] = true
+		}
+		rest, err := t.statements(list[1:])
+		if err != nil {
+			return "", err
+		}
+		return "let (" + strings.Join(assignments, ", ") + ") " + rest, nil
+	case *ast.IfStatement:
+		test, err := t.expression(statement.Test)
+		if err != nil {
+			return "", err
+		}
+		consequent, err := t.statements([]ast.Statement{statement.Consequent})
+		if err != nil {
+			return "", err
+		}
+		// Without an else clause, the statements after the if statement
+		// are used instead
+		alternate := list[1:]
+		if statement.Alternate != nil {
+			alternate = []ast.Statement{statement.Alternate}
+		}
+		alternateValue, err := t.statements(alternate)
+		if err != nil {
+			return "", err
+		}
+		return "(" + test + " ? " + consequent + " : " + alternateValue + ")", nil
+	}
+	return "", t.unsupported(list[0])
+}
+
+// expressions translates a list of expressions separated by commas.
+func (t *functionTranslator) expressions(list []ast.Expression) (string, error) {
+	values := make([]string, len(list))
+	for i, expression := range list {
+		value, err := t.expression(expression)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+	return strings.Join(values, ", "), nil
+}
+
+func (t *functionTranslator) expression(node ast.Expression) (string, error) {
+	switch node := node.(type) {
+	case *ast.NumberLiteral:
+		switch value := node.Value.(type) {
+		case int64:
+			return strconv.FormatInt(value, 10), nil
+		case float64:
+			return strconv.FormatFloat(value, 'g', -1, 64), nil
+		}
+	case *ast.StringLiteral:
+		return strconv.Quote(node.Value), nil
+	case *ast.BooleanLiteral:
+		return strconv.FormatBool(node.Value), nil
+	case *ast.NullLiteral:
+		return "undef", nil
+	case *ast.Identifier:
+		if node.Name == "undefined" {
+			return "undef", nil
+		}
+		if t.locals[node.Name] || t.isVariable(node.Name) {
+			return node.Name, nil
+		}
+	case *ast.ArrayLiteral:
+		values, err := t.expressions(node.Value)
+		if err != nil {
+			return "", err
+		}
+		return "[" + values + "]", nil
+	case *ast.BracketExpression:
+		left, err := t.expression(node.Left)
+		if err != nil {
+			return "", err
+		}
+		member, err := t.expression(node.Member)
+		if err != nil {
+			return "", err
+		}
+		return left + "[" + member + "]", nil
+	case *ast.DotExpression:
+		if object, ok := node.Left.(*ast.Identifier); ok && object.Name == "Math" {
+			switch node.Identifier.Name {
+			case "PI":
+				return "PI", nil
+			case "E":
+				return "exp(1)", nil
+			}
+			break
+		}
+		if node.Identifier.Name == "length" {
+			left, err := t.expression(node.Left)
+			if err != nil {
+				return "", err
+			}
+			return "len(" + left + ")", nil
+		}
+	case *ast.UnaryExpression:
+		operand, err := t.expression(node.Operand)
+		if err != nil {
+			return "", err
+		}
+		switch node.Operator {
+		case token.MINUS:
+			return "-" + operand, nil
+		case token.PLUS:
+			return operand, nil
+		case token.NOT:
+			return "!" + operand, nil
+		}
+	case *ast.BinaryExpression:
+		operator, ok := scadOperators[node.Operator]
+		if !ok {
+			break
+		}
+		left, err := t.expression(node.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := t.expression(node.Right)
+		if err != nil {
+			return "", err
+		}
+		return "(" + left + " " + operator + " " + right + ")", nil
+	case *ast.ConditionalExpression:
+		test, err := t.expression(node.Test)
+		if err != nil {
+			return "", err
+		}
+		consequent, err := t.expression(node.Consequent)
+		if err != nil {
+			return "", err
+		}
+		alternate, err := t.expression(node.Alternate)
+		if err != nil {
+			return "", err
+		}
+		return "(" + test + " ? " + consequent + " : " + alternate + ")", nil
+	case *ast.CallExpression:
+		args, err := t.expressions(node.ArgumentList)
+		if err != nil {
+			return "", err
+		}
+		switch callee := node.Callee.(type) {
+		case *ast.Identifier:
+			if !t.locals[callee.Name] && t.isFunction(callee.Name) {
+				return callee.Name + "(" + args + ")", nil
+			}
+		case *ast.DotExpression:
+			object, ok := callee.Left.(*ast.Identifier)
+			format, known := scadMathFunctions[callee.Identifier.Name]
+			if ok && object.Name == "Math" && known {
+				return fmt.Sprintf(format, args), nil
+			}
+		}
+	}
+	return "", t.unsupported(node)
+}
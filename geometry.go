@@ -0,0 +1,294 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// miterLimit is the largest distance, as a multiple of half the pen size,
+// that the corner of a join may extend from the line before --strict warns
+// about it.
+const miterLimit = 4
+
+// turnAngle returns the angle turned between two headings, normalized to
+// the range (-180, 180].
+func turnAngle(headingPrev, headingNext float64) float64 {
+	turn := math.Mod(headingNext-headingPrev, 360)
+	if turn <= -180 {
+		turn += 360
+	} else if turn > 180 {
+		turn -= 360
+	}
+	return turn
+}
+
+// miterRatio returns how far the corner of a join extends from the line, as
+// a multiple of half the pen size, when the line turns by the given angle.
+func miterRatio(turn float64) float64 {
+	return 1 / math.Abs(degCos(turn/2))
+}
+
+// selfIntersects reports whether any two edges of a closed polygon cross
+// each other.  Edges which only touch at a shared end point do not count.
+func selfIntersects(points [][2]float64) bool {
+	n := len(points)
+	for i := 0; i < n; i++ {
+		a1, a2 := points[i], points[(i+1)%n]
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				// These edges share the polygon's first point
+				continue
+			}
+			b1, b2 := points[j], points[(j+1)%n]
+			if segmentsCross(a1, a2, b1, b2) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// segmentsCross reports whether the segments a1-a2 and b1-b2 cross at a
+// point in the interior of both segments.
+func segmentsCross(a1, a2, b1, b2 [2]float64) bool {
+	cross := func(o, p, q [2]float64) float64 {
+		return (p[0]-o[0])*(q[1]-o[1]) - (p[1]-o[1])*(q[0]-o[0])
+	}
+	d1 := cross(b1, b2, a1)
+	d2 := cross(b1, b2, a2)
+	d3 := cross(a1, a2, b1)
+	d4 := cross(a1, a2, b2)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// signedArea returns the area of a polygon, which is positive if its points
+// go counterclockwise and negative if they go clockwise.
+func signedArea(points [][2]float64) float64 {
+	area := 0.0
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		area += p[0]*q[1] - q[0]*p[1]
+	}
+	return area / 2
+}
+
+// boundingBox is the smallest rectangle containing a set of points.
+type boundingBox struct {
+	Min, Max [2]float64
+	// Empty is true if no points have been added.
+	Empty bool
+}
+
+func newBoundingBox() boundingBox {
+	return boundingBox{Empty: true}
+}
+
+// add expands the bounding box to contain the given point.
+func (b *boundingBox) add(x, y float64) {
+	if b.Empty {
+		b.Min = [2]float64{x, y}
+		b.Max = [2]float64{x, y}
+		b.Empty = false
+		return
+	}
+	b.Min[0] = math.Min(b.Min[0], x)
+	b.Min[1] = math.Min(b.Min[1], y)
+	b.Max[0] = math.Max(b.Max[0], x)
+	b.Max[1] = math.Max(b.Max[1], y)
+}
+
+// mirrorPoint mirrors a point across a line through the origin at the given
+// angle in degrees.
+func mirrorPoint(x, y, angle float64) (float64, float64) {
+	cos, sin := degCos(angle), degSin(angle)
+	dot := x*cos + y*sin
+	return 2*dot*cos - x, 2*dot*sin - y
+}
+
+// lineHeadings returns the heading of each segment of a line.
+func lineHeadings(points []TurtlePoint) []float64 {
+	headings := make([]float64, len(points)-1)
+	for i := range headings {
+		headings[i] = radToDeg(math.Atan2(
+			points[i+1].Y-points[i].Y,
+			points[i+1].X-points[i].X))
+	}
+	return headings
+}
+
+// replaceCorners calls fn for each corner of a line, with the point at the
+// corner, the headings of the segments before and after it, the angle
+// turned, and the largest distance along either segment that may be used
+// (half of the shorter segment).  The corner is replaced with the points
+// that fn returns.
+func replaceCorners(points []TurtlePoint, fn func(point TurtlePoint, headingIn, headingOut, turn, maxSetback float64) []TurtlePoint) []TurtlePoint {
+	if len(points) < 3 {
+		return points
+	}
+	result := []TurtlePoint{points[0]}
+	for i := 1; i < len(points)-1; i++ {
+		prev, point, next := points[i-1], points[i], points[i+1]
+		lengthIn := math.Hypot(point.X-prev.X, point.Y-prev.Y)
+		lengthOut := math.Hypot(next.X-point.X, next.Y-point.Y)
+		headingIn := radToDeg(math.Atan2(point.Y-prev.Y, point.X-prev.X))
+		headingOut := radToDeg(math.Atan2(next.Y-point.Y, next.X-point.X))
+		turn := turnAngle(headingIn, headingOut)
+		if turn == 0 || math.Abs(turn) == 180 || lengthIn == 0 || lengthOut == 0 {
+			result = append(result, point)
+			continue
+		}
+		result = append(result,
+			fn(point, headingIn, headingOut, turn, math.Min(lengthIn, lengthOut)/2)...)
+	}
+	return append(result, points[len(points)-1])
+}
+
+// roundCorners replaces each corner of a line with an arc.  The arc's
+// radius is given for the inside edge of the line, and is reduced if
+// necessary so that the arc uses at most half of each segment next to the
+// corner.  Arcs use one segment for each 360/EndCapSides degrees turned.
+func roundCorners(points []TurtlePoint, radius float64) []TurtlePoint {
+	return replaceCorners(points, func(point TurtlePoint, headingIn, headingOut, turn, maxSetback float64) []TurtlePoint {
+		// Find where the arc starts and ends
+		r := radius + point.Thickness/2
+		tan := math.Abs(math.Tan(degToRad(turn / 2)))
+		setback := r * tan
+		if setback > maxSetback {
+			setback = maxSetback
+			r = setback / tan
+		}
+		side := math.Copysign(90, turn)
+		startX := point.X - setback*degCos(headingIn)
+		startY := point.Y - setback*degSin(headingIn)
+		centerX := startX + r*degCos(headingIn+side)
+		centerY := startY + r*degSin(headingIn+side)
+
+		steps := int(math.Ceil(math.Abs(turn) * float64(point.EndCapSides) / 360))
+		if steps < 1 {
+			steps = 1
+		}
+		arc := make([]TurtlePoint, steps+1)
+		for j := range arc {
+			angle := headingIn - side + turn*float64(j)/float64(steps)
+			arc[j] = point
+			arc[j].X = centerX + r*degCos(angle)
+			arc[j].Y = centerY + r*degSin(angle)
+		}
+		return arc
+	})
+}
+
+// bevelCorners replaces each corner of a line with a straight cut between
+// the points the given distance before and after the corner along the
+// middle of the line.  The distance is reduced if necessary so that the cut
+// uses at most half of each segment next to the corner.
+func bevelCorners(points []TurtlePoint, setback float64) []TurtlePoint {
+	return replaceCorners(points, func(point TurtlePoint, headingIn, headingOut, turn, maxSetback float64) []TurtlePoint {
+		d := math.Min(setback, maxSetback)
+		start, end := point, point
+		start.X -= d * degCos(headingIn)
+		start.Y -= d * degSin(headingIn)
+		end.X += d * degCos(headingOut)
+		end.Y += d * degSin(headingOut)
+		return []TurtlePoint{start, end}
+	})
+}
+
+// convexHull returns the smallest convex polygon containing the given
+// points, going clockwise like the outlines of lines.
+func convexHull(points [][2]float64) [][2]float64 {
+	sorted := append([][2]float64(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][0] != sorted[j][0] {
+			return sorted[i][0] < sorted[j][0]
+		}
+		return sorted[i][1] < sorted[j][1]
+	})
+	cross := func(o, p, q [2]float64) float64 {
+		return (p[0]-o[0])*(q[1]-o[1]) - (p[1]-o[1])*(q[0]-o[0])
+	}
+
+	// Build the upper and lower halves of the hull (Andrew's monotone chain
+	// algorithm), keeping only clockwise turns
+	var hull [][2]float64
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, point := range sorted {
+			for len(hull) >= start+2 && cross(hull[len(hull)-2], hull[len(hull)-1], point) >= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, point)
+		}
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+	return hull
+}
+
+// resampleLine adds points along a line so that no segment is longer than
+// the given length.  The added points copy the pen settings of the point
+// at the start of their segment, except for their height, which changes
+// linearly along it.
+func resampleLine(points []TurtlePoint, headings []float64, maxLength float64) ([]TurtlePoint, []float64) {
+	resampled := []TurtlePoint{points[0]}
+	var resampledHeadings []float64
+	for i := 1; i < len(points); i++ {
+		prev, point := points[i-1], points[i]
+		length := math.Hypot(point.X-prev.X, point.Y-prev.Y)
+		steps := 1
+		if maxLength > 0 {
+			steps = int(math.Max(1, math.Ceil(length/maxLength-1e-9)))
+		}
+		for j := 1; j <= steps; j++ {
+			added := point
+			if j < steps {
+				added = prev
+				added.X += (point.X - prev.X) * float64(j) / float64(steps)
+				added.Y += (point.Y - prev.Y) * float64(j) / float64(steps)
+				added.Height += (point.Height - prev.Height) * float64(j) / float64(steps)
+			}
+			resampled = append(resampled, added)
+			resampledHeadings = append(resampledHeadings, headings[i-1])
+		}
+	}
+	return resampled, resampledHeadings
+}
+
+// smoothLine replaces a line with a Catmull-Rom spline through its points,
+// using the given number of samples for each segment.  The thickness and
+// height of the line change linearly along each segment.
+func smoothLine(points []TurtlePoint, samples int) []TurtlePoint {
+	if len(points) < 3 {
+		return points
+	}
+	at := func(i int) TurtlePoint {
+		if i < 0 {
+			i = 0
+		} else if i >= len(points) {
+			i = len(points) - 1
+		}
+		return points[i]
+	}
+	spline := func(p0, p1, p2, p3, t float64) float64 {
+		return 0.5 * (2*p1 + (p2-p0)*t +
+			(2*p0-5*p1+4*p2-p3)*t*t +
+			(3*p1-p0-3*p2+p3)*t*t*t)
+	}
+	result := []TurtlePoint{points[0]}
+	for i := 1; i < len(points); i++ {
+		p0, p1, p2, p3 := at(i-2), at(i-1), at(i), at(i+1)
+		for j := 1; j <= samples; j++ {
+			t := float64(j) / float64(samples)
+			point := p2
+			point.X = spline(p0.X, p1.X, p2.X, p3.X, t)
+			point.Y = spline(p0.Y, p1.Y, p2.Y, p3.Y, t)
+			point.Thickness = p1.Thickness + (p2.Thickness-p1.Thickness)*t
+			point.Height = p1.Height + (p2.Height-p1.Height)*t
+			result = append(result, point)
+		}
+	}
+	return result
+}
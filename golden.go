@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// goldenResult is the result of compiling a script and comparing its output
+// with the expected output saved alongside it.
+type goldenResult struct {
+	Name string `json:"name"`
+	// Status is "pass", "fail", "updated" or "error".
+	Status string `json:"status"`
+	// Error describes why the script could not be compiled or its expected
+	// output could not be read or written.
+	Error string `json:"error,omitempty"`
+	// Diffs lists the differences between the actual and expected output
+	// of each file that doesn't match, keyed by the expected output's file
+	// name.
+	Diffs map[string][]diffmatchpatch.Diff `json:"-"`
+}
+
+// runGoldenTest compiles a script and compares its output with the
+// expected output in the .scad file alongside it.  If the script also has a
+// .warnings file, the geometry warnings from --strict are compared with it
+// too.  The script is compiled with the given options.  If update is true,
+// the expected output is overwritten instead.
+func runGoldenTest(path string, opts compileOptions, update bool) goldenResult {
+	result := goldenResult{Name: filepath.Base(path)}
+	fail := func(err error) goldenResult {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	// Read input file
+	input, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Process it, checking for warnings if the test has a .warnings file
+	warningsPath := path + ".warnings"
+	_, err = os.Stat(warningsPath)
+	checkWarnings := err == nil
+	warnings := ""
+	opts.BaseDir = filepath.Dir(path)
+	if checkWarnings {
+		opts.Warn = func(warning diagnostic) {
+			warnings += warning.String() + "\n"
+		}
+	}
+	output, err := jsToScad(string(input), opts)
+	if err != nil {
+		result.Status = "error"
+		result.Error = formatError(err)
+		return result
+	}
+
+	expected := map[string]string{path + ".scad": output}
+	if checkWarnings {
+		expected[warningsPath] = warnings
+	}
+
+	// Optional: Write output files
+	if update {
+		for expectedPath, actual := range expected {
+			if err := ioutil.WriteFile(expectedPath, []byte(actual), 0644); err != nil {
+				return fail(err)
+			}
+		}
+		result.Status = "updated"
+		return result
+	}
+
+	// Read expected output and compare
+	result.Status = "pass"
+	for expectedPath, actual := range expected {
+		expectedBytes, err := ioutil.ReadFile(expectedPath)
+		if err != nil {
+			return fail(err)
+		}
+		if actual != string(expectedBytes) {
+			dmp := diffmatchpatch.New()
+			if result.Diffs == nil {
+				result.Diffs = map[string][]diffmatchpatch.Diff{}
+			}
+			result.Diffs[filepath.Base(expectedPath)] =
+				dmp.DiffMain(actual, string(expectedBytes), false)
+			result.Status = "fail"
+		}
+	}
+	return result
+}
+
+// sortedDiffNames returns the names of the files whose expected output
+// didn't match, in order.
+func sortedDiffNames(result goldenResult) []string {
+	var names []string
+	for name := range result.Diffs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
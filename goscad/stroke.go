@@ -0,0 +1,149 @@
+// Package goscad draws lines with a round pen, like the go-scad turtle, and
+// writes them as OpenSCAD polygons.  It can be used from Go programs without
+// going through the JavaScript interpreter used by the go-scad command.
+package goscad
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Point is a point on a line, with the settings of the pen there.
+type Point struct {
+	X         float64
+	Y         float64
+	Thickness float64
+	// EndCapSides is the number of sides used to draw a full circle at the
+	// ends of the line.  It must be even.
+	EndCapSides int
+	// Height is how high the line is extruded at this point when it is
+	// drawn in 3D, or 0 for a flat line.  Outline doesn't use it.
+	Height float64
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degCos(deg float64) float64 {
+	return math.Cos(degToRad(deg))
+}
+
+func degSin(deg float64) float64 {
+	return math.Sin(degToRad(deg))
+}
+
+// Outline returns the outline of a line drawn through the given points with
+// a round pen, going clockwise.  headings gives the direction of each
+// segment of the line in degrees, so it has one fewer element than points.
+// A line with only one point is drawn as a dot.
+//
+// The outline is split into runs of points: the cap at the start of the
+// line, the joins along its left side, the cap at its end, and the joins
+// along its right side.  Runs without any points are left out.
+func Outline(points []Point, headings []float64) [][][2]float64 {
+	at := func(point Point, angle float64) [2]float64 {
+		return [2]float64{
+			point.X + point.Thickness/2*degCos(angle),
+			point.Y + point.Thickness/2*degSin(angle),
+		}
+	}
+
+	if len(points) == 1 {
+		// Degenerate case: just draw an end cap
+		point := points[0]
+		dot := make([][2]float64, point.EndCapSides)
+		for j := range dot {
+			dot[j] = at(point, float64(j)*360/float64(point.EndCapSides))
+		}
+		return [][][2]float64{dot}
+	}
+
+	// endCap draws half a circle around a point, starting from the given
+	// angle
+	endCap := func(point Point, angle float64) [][2]float64 {
+		var run [][2]float64
+		for j := 0; j <= point.EndCapSides/2; j++ {
+			run = append(run, at(point, angle-float64(j)*360/float64(point.EndCapSides)))
+		}
+		return run
+	}
+
+	// join finds the corner where the edges of the pen strokes on either
+	// side of point i meet, on the left (d == 1) or right (d == -1) side
+	join := func(i, d int) [2]float64 {
+		point := points[i]
+		headingPrev, headingNext := headings[i-1], headings[i]
+		if d == -1 {
+			headingPrev, headingNext = headingNext, headingPrev
+		}
+		if headingPrev == headingNext {
+			// Degenerate case: both segments being joined have the same
+			// heading.  The end of the current pen-stroke is the start of
+			// the next pen-stroke, no need to calculate more.
+			return at(point, headingPrev+float64(90*d))
+		}
+
+		// Need to calculate the point marked with an 'x' in the diagram
+		// below, which is the intersection of the edges of the current
+		// pen-stroke (line between points 1-2) and the next pen-stroke
+		// (line between points 3-4):
+		//
+		//       / .  4
+		//   ----    /
+		//   .   .  /
+		//  1------x2
+		//        3
+		//
+		headingEdgePrev := headingPrev + float64(90*d)
+		headingEdgeNext := headingNext + float64(90*d)
+		p1 := at(points[i-d], headingEdgePrev)
+		p2 := at(point, headingEdgePrev)
+		p3 := at(point, headingEdgeNext)
+		p4 := at(points[i+d], headingEdgeNext)
+		x1, y1, x2, y2 := p1[0], p1[1], p2[0], p2[1]
+		x3, y3, x4, y4 := p3[0], p3[1], p4[0], p4[1]
+		// https://en.wikipedia.org/wiki/Line%E2%80%93line_intersection#Given_two_points_on_each_line
+		denom := (x1-x2)*(y3-y4) - (y1-y2)*(x3-x4)
+		return [2]float64{
+			((x1*y2-y1*x2)*(x3-x4) - (x1-x2)*(x3*y4-y3*x4)) / denom,
+			((x1*y2-y1*x2)*(y3-y4) - (y1-y2)*(x3*y4-y3*x4)) / denom,
+		}
+	}
+
+	// Go along the line and back again to draw its left and right edges
+	last := len(points) - 1
+	runs := [][][2]float64{endCap(points[0], headings[0]-90)}
+	var left, right [][2]float64
+	for i := 1; i < last; i++ {
+		left = append(left, join(i, 1))
+	}
+	for i := last - 1; i >= 1; i-- {
+		right = append(right, join(i, -1))
+	}
+	if len(left) > 0 {
+		runs = append(runs, left)
+	}
+	runs = append(runs, endCap(points[last], headings[last-1]+90))
+	if len(right) > 0 {
+		runs = append(runs, right)
+	}
+	return runs
+}
+
+var stripZeroes = regexp.MustCompile(`\.?0+$`)
+
+// FormatFloat formats a number for OpenSCAD code with at most the given
+// number of digits after the decimal point, and without trailing zeroes.
+func FormatFloat(n float64, precision int) string {
+	str := strconv.FormatFloat(n, 'f', precision, 64)
+	if strings.Contains(str, ".") {
+		str = stripZeroes.ReplaceAllString(str, "")
+	}
+	if str == "-0" {
+		str = "0"
+	}
+	return str
+}
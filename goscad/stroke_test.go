@@ -0,0 +1,28 @@
+package goscad
+
+import "testing"
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		n         float64
+		precision int
+		expected  string
+	}{
+		{100, 0, "100"},
+		{0, 0, "0"},
+		{2.5, 0, "2"},
+		{-0.4, 0, "0"},
+		{100, 1, "100"},
+		{1.25, 1, "1.2"},
+		{-0.04, 1, "0"},
+		{100, 6, "100"},
+		{10.5, 6, "10.5"},
+		{1.0 / 3, 6, "0.333333"},
+		{-1e-9, 6, "0"},
+	}
+	for _, test := range tests {
+		if actual := FormatFloat(test.n, test.precision); actual != test.expected {
+			t.Errorf("FormatFloat(%v, %d) = %q, expected %q", test.n, test.precision, actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package goscad
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Turtle draws lines by moving around like a turtle holding a pen, and
+// writes each line to an io.Writer as an OpenSCAD polygon when the pen is
+// lifted.  Lengths are in millimeters and angles are in degrees.
+//
+// Errors are remembered instead of being returned by each method, and once
+// an error has happened the turtle stops writing.  Check Err after drawing.
+type Turtle struct {
+	// X and Y are the turtle's position, and Heading is the direction it is
+	// facing, counterclockwise from the X axis.
+	X, Y    float64
+	Heading float64
+	// PenSize is the thickness of the lines drawn.  If it is 0 when the pen
+	// is put down, the points of the line are written as a filled polygon
+	// instead.
+	PenSize float64
+	// EndCapSides is the number of sides used to draw a full circle at the
+	// ends of lines.  It must be even.
+	EndCapSides int
+	// Precision is the largest number of digits written after the decimal
+	// point in numbers.
+	Precision int
+	// Indent is written before each line of the output.
+	Indent string
+
+	w         io.Writer
+	pendown   bool
+	zeroWidth bool
+	points    []Point
+	headings  []float64
+	err       error
+}
+
+// NewTurtle returns a turtle at the origin facing along the X axis, with a
+// pen size of 1 that is up, which writes its lines to w.
+func NewTurtle(w io.Writer) *Turtle {
+	return &Turtle{
+		PenSize:     1,
+		EndCapSides: 60,
+		Precision:   6,
+		w:           w,
+	}
+}
+
+// Err returns the first error that happened while drawing, if any.
+func (t *Turtle) Err() error {
+	return t.err
+}
+
+func (t *Turtle) fail(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// IsDown reports whether the pen is down.
+func (t *Turtle) IsDown() bool {
+	return t.pendown
+}
+
+// point returns the turtle's position with the current pen settings.
+func (t *Turtle) point() Point {
+	if t.EndCapSides < 2 || t.EndCapSides%2 == 1 {
+		t.fail(fmt.Errorf("invalid EndCapSides value: %d", t.EndCapSides))
+	}
+	if t.PenSize < 0 {
+		t.fail(errors.New("pen size set to less than 0"))
+	} else if t.zeroWidth != (t.PenSize == 0) {
+		t.fail(errors.New("pen size changed to or from 0 while drawing a line"))
+	}
+	return Point{X: t.X, Y: t.Y, Thickness: t.PenSize, EndCapSides: t.EndCapSides}
+}
+
+// PenDown puts the pen down, so that moving the turtle draws a line.
+func (t *Turtle) PenDown() {
+	if t.pendown {
+		return
+	}
+	t.pendown = true
+	t.zeroWidth = t.PenSize == 0
+	t.points = []Point{t.point()}
+	t.headings = nil
+}
+
+// PenUp lifts the pen up and writes the line drawn since it was put down.
+func (t *Turtle) PenUp() {
+	if !t.pendown {
+		return
+	}
+	t.pendown = false
+	if t.err != nil {
+		return
+	}
+
+	var runs [][][2]float64
+	if t.zeroWidth {
+		if len(t.points) < 3 {
+			t.fail(errors.New("polygon drawn with pen size 0 has fewer than 3 points"))
+			return
+		}
+		var run [][2]float64
+		for _, point := range t.points {
+			run = append(run, [2]float64{point.X, point.Y})
+		}
+		runs = [][][2]float64{run}
+	} else {
+		runs = Outline(t.points, t.headings)
+	}
+
+	var out strings.Builder
+	out.WriteString(t.Indent + "polygon(points = [\n" + t.Indent + "\t")
+	for i, run := range runs {
+		if i > 0 {
+			out.WriteString("\n" + t.Indent + "\t")
+		}
+		for j, point := range run {
+			out.WriteString("[" + FormatFloat(point[0], t.Precision) + "," +
+				FormatFloat(point[1], t.Precision) + "],")
+			if j < len(run)-1 {
+				out.WriteString(" ")
+			}
+		}
+	}
+	out.WriteString("\n" + t.Indent + "]);\n")
+	if _, err := io.WriteString(t.w, out.String()); err != nil {
+		t.fail(err)
+	}
+}
+
+// addPoint adds the turtle's position to the line being drawn, if the pen
+// is down.
+func (t *Turtle) addPoint(heading float64) {
+	if t.pendown {
+		t.points = append(t.points, t.point())
+		t.headings = append(t.headings, heading)
+	}
+}
+
+// Forward moves the turtle forward in the direction it is facing.
+func (t *Turtle) Forward(distance float64) {
+	t.X += distance * degCos(t.Heading)
+	t.Y += distance * degSin(t.Heading)
+	t.addPoint(t.Heading)
+}
+
+// Back moves the turtle backward without turning around.
+func (t *Turtle) Back(distance float64) {
+	t.Forward(-distance)
+}
+
+// Left turns the turtle counterclockwise by the given angle.
+func (t *Turtle) Left(angle float64) {
+	t.Heading += angle
+}
+
+// Right turns the turtle clockwise by the given angle.
+func (t *Turtle) Right(angle float64) {
+	t.Heading -= angle
+}
+
+// SetPos moves the turtle to the given position without changing its
+// heading.
+func (t *Turtle) SetPos(x, y float64) {
+	heading := math.Atan2(y-t.Y, x-t.X) * 180 / math.Pi
+	t.X, t.Y = x, y
+	t.addPoint(heading)
+}
+
+// Jump moves the turtle by the given offsets without drawing, like jump() in
+// a go-scad script.  If the pen is down, the line being drawn ends here and a
+// new one starts at the new position.
+func (t *Turtle) Jump(dx, dy float64) {
+	t.JumpTo(t.X+dx, t.Y+dy)
+}
+
+// JumpTo moves the turtle to the given position without drawing.  If the pen
+// is down, the line being drawn ends here and a new one starts at the new
+// position.
+func (t *Turtle) JumpTo(x, y float64) {
+	pendown := t.pendown
+	if len(t.points) == 1 {
+		// Nothing has been drawn yet, so don't leave a dot behind
+		t.pendown = false
+	}
+	t.PenUp()
+	t.X, t.Y = x, y
+	if pendown {
+		t.PenDown()
+	}
+}
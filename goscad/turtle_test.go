@@ -0,0 +1,80 @@
+package goscad
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The turtle should draw the same lines as the go-scad scripts in the test
+// directory that do the same things.
+func TestTurtleMatchesScripts(t *testing.T) {
+	tests := map[string]func(turtle *Turtle){
+		"basic-dot-6.js": func(turtle *Turtle) {
+			turtle.PenDown()
+			turtle.PenUp()
+		},
+		"corner-90.js": func(turtle *Turtle) {
+			turtle.Left(90)
+			turtle.Forward(.5)
+			turtle.Right(90)
+			turtle.PenDown()
+			turtle.Forward(5)
+			turtle.Left(90)
+			turtle.Forward(5)
+			turtle.PenUp()
+		},
+		"jump.js": func(turtle *Turtle) {
+			turtle.EndCapSides = 4
+			turtle.PenDown()
+			turtle.Forward(5)
+			turtle.JumpTo(7, 0)
+			turtle.Forward(5)
+			turtle.Jump(0, 3)
+			turtle.Left(90)
+			turtle.Forward(2)
+			turtle.PenUp()
+			turtle.Jump(1, 1)
+		},
+	}
+	for name, draw := range tests {
+		scad, err := ioutil.ReadFile(filepath.Join("..", "test", name+".scad"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := withoutEcho(string(scad))
+		var out strings.Builder
+		turtle := NewTurtle(&out)
+		turtle.EndCapSides = 6
+		draw(turtle)
+		if err := turtle.Err(); err != nil {
+			t.Errorf("%s: %s", name, err)
+		} else if out.String() != expected {
+			t.Errorf("%s: expected:\n%s\nactual:\n%s", name, expected, out.String())
+		}
+	}
+}
+
+// withoutEcho removes the comments that scripts echo into their output, such
+// as their bounds, which the turtle has no way to write.
+func withoutEcho(scad string) string {
+	var lines []string
+	for _, line := range strings.SplitAfter(scad, "\n") {
+		if !strings.HasPrefix(line, "// ") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func TestTurtleErrors(t *testing.T) {
+	turtle := NewTurtle(ioutil.Discard)
+	turtle.EndCapSides = 5
+	turtle.PenDown()
+	turtle.Forward(1)
+	turtle.PenUp()
+	if turtle.Err() == nil {
+		t.Error("expected an error for an odd EndCapSides")
+	}
+}
@@ -0,0 +1,56 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInit(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"size.js":     "var SIZE = 3;",
+		"helpers.js":  "var LENGTH = SIZE * 2;\nfunction line(n) { pendown(); forward(n); penup(); }",
+		"plugin.js":   "register('twice', function(n) { return 2 * n; });",
+		"main.js":     "end_cap_sides(4); line(LENGTH);",
+		"bad.js":      "nonexistent();",
+		"lib/cfg.js":  "var SIZE = 4;",
+		"goscad.json": `{"init": ["lib/cfg.js"]}`,
+		"twice.js":    "var SIZE = twice(1);",
+	})
+	line := func(length string) string {
+		return "polygon(points = [\n" +
+			"\t[0,-0.5], [-0.5,0], [0,0.5],\n" +
+			"\t[" + length + ",0.5], [" + length + ".5,0], [" + length + ",-0.5],\n" +
+			"]);\n"
+	}
+	tests := []struct {
+		args           []string
+		stdout, stderr string
+		status         int
+	}{
+		{[]string{"--no-config", "--init", "size.js", "--init", "helpers.js", "main.js"}, line("6"), "", 0},
+		// The init scripts run in the order given
+		{[]string{"--no-config", "--init", "helpers.js", "--init", "size.js", "main.js"}, "",
+			"JavaScript error: ReferenceError: 'SIZE' is not defined\n    at helpers.js:1:14\n", 1},
+		// Init scripts run after plugins, so they can use their commands
+		{[]string{"--no-config", "--init", "twice.js", "--plugin", "plugin.js", "--init", "helpers.js", "main.js"}, line("4"), "", 0},
+		// The config file's init scripts run first
+		{[]string{"--init", "helpers.js", "main.js"}, line("8"), "", 0},
+		{[]string{"--no-config", "--init", "bad.js", "main.js"}, "",
+			"JavaScript error: ReferenceError: 'nonexistent' is not defined\n    at bad.js:1:1\n", 1},
+		{[]string{"--no-config", "--json-errors", "--init", "bad.js", "main.js"}, "",
+			`{"file":"bad.js","line":1,"column":1,"severity":"error","message":"ReferenceError: 'nonexistent' is not defined"}` + "\n", 1},
+		{[]string{"--no-config", "--init", "missing.js", "main.js"}, "",
+			"open missing.js: no such file or directory\n", 1},
+	}
+	for _, test := range tests {
+		result := runCommand(t, dir, "", test.args...)
+		if result.status != test.status || result.stdout != test.stdout || result.stderr != test.stderr {
+			t.Errorf("%s: exit status %d, stdout:\n%s\nstderr:\n%s\nexpected %d,\n%s\nand\n%s",
+				strings.Join(test.args, " "), result.status, result.stdout, result.stderr,
+				test.status, test.stdout, test.stderr)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build !js
+// +build !js
+
+package main
+
+import "testing"
+
+func TestJSONErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"reference.js": "pendown();\nforward(1);\nnonexistent();\n",
+		"syntax.js":    "pendown(\n",
+		"warning.js":   "pendown();\nforward(0);\npenup();\n",
+	})
+	tests := []struct {
+		args   []string
+		stderr string
+		status int
+	}{{
+		[]string{"reference.js"},
+		`{"file":"reference.js","line":3,"column":1,"severity":"error","message":"ReferenceError: 'nonexistent' is not defined"}` + "\n",
+		1,
+	}, {
+		[]string{"syntax.js"},
+		`{"file":"syntax.js","line":2,"column":1,"severity":"error","message":"SyntaxError: Unexpected end of input"}` + "\n",
+		1,
+	}, {
+		[]string{"--strict", "warning.js"},
+		`{"file":"warning.js","line":2,"column":1,"severity":"warning","message":"Zero-length forward move"}` + "\n",
+		0,
+	}, {
+		[]string{"--strict-fail", "warning.js"},
+		`{"file":"warning.js","line":2,"column":1,"severity":"warning","message":"Zero-length forward move"}` + "\n",
+		1,
+	}, {
+		[]string{"--strict", "warning.js", "reference.js"},
+		`{"file":"warning.js","line":2,"column":1,"severity":"warning","message":"Zero-length forward move"}` + "\n" +
+			`{"file":"reference.js","line":3,"column":1,"severity":"error","message":"ReferenceError: 'nonexistent' is not defined"}` + "\n",
+		1,
+	}, {
+		[]string{"nonexistent.js"},
+		`{"file":"nonexistent.js","severity":"error","message":"open nonexistent.js: no such file or directory"}` + "\n",
+		1,
+	}}
+	for _, test := range tests {
+		result := runCommand(t, dir, "", append([]string{"--json-errors"}, test.args...)...)
+		if result.status != test.status || result.stderr != test.stderr {
+			t.Errorf("%v: exit status %d, stderr:\n%s\nexpected %d and:\n%s",
+				test.args, result.status, result.stderr, test.status, test.stderr)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxLsystemLength limits the size of expanded L-systems, which grow
+// exponentially with the number of iterations.
+const maxLsystemLength = 10000000
+
+// expandLsystem applies the given rules to the axiom the given number of
+// times, replacing each symbol that has a rule with the rule's result.
+func expandLsystem(axiom string, rules map[rune]string, iterations int) (string, error) {
+	if iterations < 0 {
+		return "", fmt.Errorf("Invalid L-system iterations value: %d", iterations)
+	}
+	result := axiom
+	for i := 0; i < iterations; i++ {
+		var next strings.Builder
+		for _, symbol := range result {
+			if rule, ok := rules[symbol]; ok {
+				next.WriteString(rule)
+			} else {
+				next.WriteRune(symbol)
+			}
+			if next.Len() > maxLsystemLength {
+				return "", fmt.Errorf(
+					"L-system expanded to more than %d symbols after %d iterations",
+					maxLsystemLength, i+1)
+			}
+		}
+		result = next.String()
+	}
+	return result, nil
+}
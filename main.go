@@ -1,452 +1,570 @@
+//go:build !js
+// +build !js
+
 package main
 
 import (
 	"github.com/alexflint/go-arg"
-	"github.com/robertkrimen/otto"
 
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
-	"regexp"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type args struct {
-	Filename string `arg:"positional,required" help:"JavaScript input file"`
-}
-
-func (args) Description() string {
-	return ("Compiles go-scad code (JavaScript with a Turtle Graphics-like" +
-		" library) into OpenSCAD code.")
+// globalArgs holds the flags shared by the subcommands which compile
+// scripts.  Their defaults are read from the project config file.
+type globalArgs struct {
+	Precision *int     `help:"largest number of digits written after the decimal point in numbers in the output, at least 1 (default: 6)"`
+	Indent    *int     `help:"indent the output with this many spaces instead of tabs, or 0 for tabs"`
+	Define    []string `arg:"-D,separate" help:"set a global variable before scripts run, given as NAME=VALUE where VALUE is JSON or a string"`
+	Plugin    []string `arg:"--plugin,separate" help:"run this JavaScript file before each script, usually to register() extra commands"`
+	Init      []string `arg:"--init,separate" help:"run this JavaScript file after any plugins and before each script, usually to define shared constants and helper functions"`
+	NoConfig  bool     `arg:"--no-config" help:"don't read settings from a goscad.json or .goscadrc file"`
 }
 
-var vm *otto.Otto
-
-func toJsValue(value interface{}) otto.Value {
-	jsValue, err := vm.ToValue(value)
-	if err != nil {
-		log.Fatal(err)
+// load reads the project config file, unless --no-config was given, and
+// returns it along with the compile options given by the global flags or
+// the config file.  Invalid flags are reported using p.
+func (g globalArgs) load(p *arg.Parser) (compileOptions, projectConfig) {
+	var config projectConfig
+	if !g.NoConfig {
+		path, err := findConfig(".")
+		if err == nil && path != "" {
+			config, err = loadConfig(path)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
-	return jsValue
-}
-
-func toFloat(value otto.Value) float64 {
-	if value.IsUndefined() {
-		log.Fatal("Undefined value passed to toFloat()")
+	if g.Precision == nil {
+		g.Precision = config.Precision
 	}
-	floatValue, err := value.ToFloat()
-	if err != nil {
-		log.Fatal(err)
+	if g.Indent == nil {
+		g.Indent = &config.Indent
 	}
-	return floatValue
-}
-
-func toInt(value otto.Value) int {
-	if value.IsUndefined() {
-		log.Fatal("Undefined value passed to toInt()")
+	// compileOptions uses 0 for the default precision, so it can't be given
+	precision := 0
+	if g.Precision != nil {
+		precision = *g.Precision
+		if precision < 1 {
+			p.Fail("--precision must be at least 1")
+		}
 	}
-	int64Value, err := value.ToInteger()
-	if err != nil {
-		log.Fatal(err)
+	if *g.Indent < 0 {
+		p.Fail("--indent must not be negative")
+	}
+	indent := ""
+	if *g.Indent > 0 {
+		indent = strings.Repeat(" ", *g.Indent)
+	}
+	defines := map[string]interface{}{}
+	for name, value := range config.Defines {
+		defines[name] = value
+	}
+	for _, define := range g.Define {
+		parts := strings.SplitN(define, "=", 2)
+		if len(parts) != 2 {
+			p.Fail("-D must be given as NAME=VALUE")
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(parts[1]), &value); err != nil {
+			value = parts[1]
+		}
+		defines[parts[0]] = value
 	}
-	return int(int64Value)
-}
 
-func toString(value otto.Value) string {
-	if value.IsUndefined() {
-		log.Fatal("Undefined value passed to toString()")
+	pluginPaths, initPaths := g.scriptPaths(config)
+	plugins, err := readScriptFiles(pluginPaths)
+	if err != nil {
+		log.Fatal(err)
 	}
-	stringValue, err := value.ToString()
+	initScripts, err := readScriptFiles(initPaths)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return stringValue
+	return compileOptions{
+		Precision: precision,
+		Indent:    indent,
+		Defines:   defines,
+		Plugins:   plugins,
+		Init:      initScripts,
+	}, config
 }
 
-func degToRad(deg float64) float64 {
-	return deg * math.Pi / 180
+// scriptPaths returns the plugin and init scripts to run, from the config
+// file and then the command line.
+func (g globalArgs) scriptPaths(config projectConfig) ([]string, []string) {
+	plugins := append(append([]string{}, config.Plugins...), g.Plugin...)
+	initScripts := append(append([]string{}, config.Init...), g.Init...)
+	return plugins, initScripts
 }
 
-func radToDeg(rad float64) float64 {
-	return rad * 180 / math.Pi
-}
-
-func degCos(deg float64) float64 {
-	return math.Cos(degToRad(deg))
-}
-
-func degSin(deg float64) float64 {
-	return math.Sin(degToRad(deg))
+// parseArgs parses the arguments of a subcommand into dest, printing its
+// help and exiting if asked to.
+func parseArgs(program string, argv []string, dest interface{}) *arg.Parser {
+	p, err := arg.NewParser(arg.Config{Program: program}, dest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := p.Parse(argv); err == arg.ErrHelp {
+		p.WriteHelp(os.Stdout)
+		os.Exit(0)
+	} else if err != nil {
+		p.Fail(err.Error())
+	}
+	return p
 }
 
-type TurtlePoint struct {
-	X           float64
-	Y           float64
-	Thickness   float64
-	EndCapSides int
+type args struct {
+	globalArgs
+	Filenames        []string `arg:"positional" help:"JavaScript input files.  If more than one file is given, each file's output is written to a .scad file alongside it"`
+	Seed             int64    `help:"initial seed for random numbers generated by scripts"`
+	EmitDts          string   `arg:"--emit-dts" help:"write TypeScript definitions for the go-scad library to this file and exit"`
+	Strict           *bool    `help:"report warnings about degenerate geometry, such as zero-length moves and self-intersecting outlines.  --strict=false turns this off if the config file turns it on"`
+	StrictFail       *bool    `arg:"--strict-fail" help:"like --strict, but exit with an error if there are any warnings"`
+	Center           bool     `help:"move the drawing so that its bounding box is centered at the origin"`
+	Fit              string   `help:"scale the drawing to fit into this size, given as WIDTHxHEIGHT in millimeters, and move it to the origin (or center it, with --center)"`
+	UnionAll         bool     `arg:"--union-all" help:"wrap the whole drawing in a single union() block, so that it is one 2D region"`
+	Backend          string   `help:"how lines are written: polygon (the default) writes the polygons of their outlines, and bosl2 writes their paths as calls to BOSL2's stroke() module"`
+	Stats            bool     `help:"print statistics about the output (number of polygons and points, length of lines drawn, bounding box and compile time) to stderr"`
+	StatsJSON        bool     `arg:"--stats-json" help:"like --stats, but print a line of JSON for each file"`
+	Validate         bool     `help:"check that the polygons in the output are valid, and exit with an error if not"`
+	ValidateOpenscad bool     `arg:"--validate-openscad" help:"check the output by running the openscad binary on it, and exit with an error if it reports any problems"`
+	JSONErrors       bool     `arg:"--json-errors" help:"print errors and warnings to stderr as lines of JSON, with the file, line, column, severity and message of each"`
+	Compact          bool     `help:"write smaller output, without indentation or line breaks between points, for files that are only read by other programs"`
+	OutputDir        string   `arg:"--output-dir" help:"write each file's output to a .scad file in this directory"`
+	Preview          string   `help:"draw the output seen from above into this PNG file, without needing OpenSCAD.  Only one input file may be given.  matrix() and shear() are applied, but blocks from wrap() and scad() are ignored, with a warning"`
+	PreviewGrid      float64  `arg:"--preview-grid" help:"draw grid lines this many millimeters apart behind the --preview image"`
+	Profile          bool     `help:"print where the time is spent compiling each file to stderr: in each stage of compiling, and in each line and function of the script (sampled every millisecond)"`
 }
 
-type TurtlePolygon struct {
-	Points    []TurtlePoint
-	Headings  []float64
-	ZeroWidth bool
+func (args) Description() string {
+	return ("Compiles go-scad code (JavaScript with a Turtle Graphics-like" +
+		" library) into OpenSCAD code.  `go-scad FILE...` is short for" +
+		" `go-scad compile FILE...`.  Run `go-scad render --help`," +
+		" `go-scad watch --help`, `go-scad serve --help`, `go-scad repl" +
+		" --help` or `go-scad test --help` for information about the other" +
+		" subcommands.  Default settings are read from a goscad.json or" +
+		" .goscadrc file in the current directory or one of its parents, if" +
+		" there is one.")
 }
 
-var stripZeroes *regexp.Regexp
-
-func formatFloat(n float64) string {
-	if stripZeroes == nil {
-		stripZeroes = regexp.MustCompile(`\.?0+$`)
+func main() {
+	command, argv := "", os.Args[1:]
+	if len(argv) > 0 {
+		command = argv[0]
 	}
-	str := strconv.FormatFloat(n, 'f', 6, 64)
-	str = stripZeroes.ReplaceAllString(str, "")
-	if str == "-0" {
-		str = "0"
+	switch command {
+	case "compile":
+		compileFiles(argv[1:])
+	case "render":
+		render(argv[1:])
+	case "watch":
+		watch(argv[1:])
+	case "serve":
+		serve(argv[1:])
+	case "repl":
+		repl(argv[1:])
+	case "test":
+		runTests(argv[1:])
+	default:
+		// Compiling is the default, as it was before there were subcommands
+		compileFiles(argv)
 	}
-	return str
 }
 
-func main() {
-	// Parse arguments
+func compileFiles(argv []string) {
 	var args args
-	arg.MustParse(&args)
+	parser := parseArgs("go-scad compile", argv, &args)
 
-	// Read input file
-	jsInputBytes, err := ioutil.ReadFile(args.Filename)
-	if err != nil {
-		log.Fatal(err)
+	// Use settings from the project config file unless they are given on the
+	// command line
+	globalOpts, config := args.load(parser)
+	if args.OutputDir == "" {
+		args.OutputDir = config.OutputDir
 	}
-
-	jsInput := string(jsInputBytes)
-	output := jsToScad(jsInput)
-	fmt.Print(output)
-}
-
-func jsToScad(jsInput string) string {
-	output := ""
-
-	indentLevel := 0
-
-	outBeginPolygon := func() {
-		output += strings.Repeat("\t", indentLevel) +
-			"polygon(points = [\n" +
-			strings.Repeat("\t", indentLevel+1)
+	strict, strictFail := config.Strict, config.StrictFail
+	if args.Strict != nil {
+		strict = *args.Strict
+	}
+	if args.StrictFail != nil {
+		strictFail = *args.StrictFail
 	}
 
-	outNewLine := func() {
-		output += "\n" + strings.Repeat("\t", indentLevel+1)
+	if args.EmitDts != "" {
+		emitDts(args.EmitDts, globalOpts.Plugins)
+		return
+	}
+	if len(args.Filenames) == 0 {
+		parser.Fail("at least one filename is required")
 	}
 
-	outPoint := func(x float64, y float64, isLast bool) {
-		space := " "
-		if isLast {
-			space = ""
+	if args.Backend != "" {
+		valid := false
+		for _, backend := range outputBackends {
+			valid = valid || args.Backend == backend
+		}
+		if !valid {
+			parser.Fail("--backend must be " + strings.Join(outputBackends, " or "))
 		}
-		output += fmt.Sprintf("[%s,%s],%s",
-			formatFloat(x),
-			formatFloat(y),
-			space)
 	}
 
-	outEndPolygon := func() {
-		output += "\n" + strings.Repeat("\t", indentLevel) + "]);\n"
+	if args.Preview != "" && len(args.Filenames) > 1 {
+		parser.Fail("--preview needs exactly one input file")
 	}
-
-	outBeginBlock := func(wrapper string) {
-		output += strings.Repeat("\t", indentLevel) + wrapper + " {\n"
-		indentLevel += 1
+	if args.PreviewGrid < 0 {
+		parser.Fail("--preview-grid must not be negative")
 	}
 
-	outEndBlock := func() {
-		indentLevel -= 1
-		output += strings.Repeat("\t", indentLevel) + "}\n"
+	var fit [2]float64
+	if args.Fit != "" {
+		var err error
+		if fit, err = parseFit(args.Fit); err != nil {
+			parser.Fail(err.Error())
+		}
 	}
 
-	outEcho := func(text string) {
-		lines := strings.Split(text, "\n")
-		for _, line := range lines {
-			output += strings.Repeat("\t", indentLevel) + line + "\n"
+	// report prints an error or warning about a file to stderr
+	report := func(stderr io.Writer, filename, severity string, d diagnostic) {
+		if d.File != "" {
+			filename = d.File
+		}
+		if !args.JSONErrors {
+			logger := log.New(stderr, "", log.LstdFlags)
+			if severity == "warning" {
+				logger.Printf("%s: warning: %s", filename, d)
+			} else {
+				logger.Printf("%s: %s", filename, d.Message)
+			}
+			return
+		}
+		data, err := json.Marshal(struct {
+			File     string `json:"file"`
+			Line     int    `json:"line,omitempty"`
+			Column   int    `json:"column,omitempty"`
+			Severity string `json:"severity"`
+			Message  string `json:"message"`
+		}{filename, d.Line, d.Column, severity, d.Message})
+		if err != nil {
+			log.Fatal(err)
+		}
+		stderr.Write(append(data, '\n'))
+	}
+	reportError := func(stderr io.Writer, filename string, err error) {
+		var scriptErr scriptError
+		if !args.JSONErrors || !errors.As(err, &scriptErr) {
+			report(stderr, filename, "error", diagnostic{Message: err.Error()})
+			return
+		}
+		for _, d := range errorDiagnostics(scriptErr.err) {
+			report(stderr, filename, "error", d)
 		}
 	}
 
-	writePolygon := func(polygon TurtlePolygon) {
-		outBeginPolygon()
-
-		if polygon.ZeroWidth {
-			if len(polygon.Points) == 1 {
-				log.Fatal("Zero-width polygon with one point is invalid")
-			}
-			for i, point := range polygon.Points {
-				outPoint(
-					point.X,
-					point.Y,
-					i == len(polygon.Points)-1)
+	var warnings int32
+	fileOptions := func(filename string, stderr io.Writer) compileOptions {
+		opts := globalOpts
+		opts.Seed = args.Seed
+		opts.Center = args.Center
+		opts.Fit = fit
+		opts.Validate = args.Validate
+		opts.UnionAll = args.UnionAll
+		opts.Backend = args.Backend
+		opts.Compact = args.Compact
+		if args.Stats || args.StatsJSON {
+			opts.Stats = &compileStats{}
+		}
+		if args.Profile {
+			opts.Profile = newCompileProfile()
+		}
+		if args.Preview != "" {
+			opts.Preview = &previewDrawing{}
+		}
+		if strict || strictFail {
+			opts.Warn = func(warning diagnostic) {
+				report(stderr, filename, "warning", warning)
+				atomic.AddInt32(&warnings, 1)
 			}
-			outEndPolygon()
+		}
+		return opts
+	}
+	printStats := func(stderr io.Writer, filename string, stats *compileStats) {
+		if stats == nil {
 			return
 		}
-
-		if len(polygon.Points) == 1 {
-			// Degenerate case: just draw an end cap
-			point := polygon.Points[0]
-			for j := 0; j < point.EndCapSides; j++ {
-				angle := float64(j) * 360 / float64(point.EndCapSides)
-				outPoint(
-					point.X+point.Thickness/2*degCos(angle),
-					point.Y+point.Thickness/2*degSin(angle),
-					j == point.EndCapSides-1)
+		if args.StatsJSON {
+			data, err := json.Marshal(struct {
+				File string `json:"file"`
+				*compileStats
+				CompileMillis float64 `json:"compile_ms"`
+			}{filename, stats, stats.CompileTime.Seconds() * 1000})
+			if err != nil {
+				log.Fatal(err)
 			}
-			outEndPolygon()
+			stderr.Write(append(data, '\n'))
 			return
 		}
-
-		// Loop around the polygon's coordinates twice (first in ascending
-		// order, then in descending order) to draw the "left" (d == 1) and
-		// "right" (d == -1) edges of its pen strokes, in a clockwise fashion.
-		d := 1
-		i := 0
-		for {
-			point := polygon.Points[i]
-			if i == 0 {
-				// Draw begin cap
-				headingBegin := polygon.Headings[0]
-				for j := 0; j <= point.EndCapSides/2; j++ {
-					angle := headingBegin - 90 - float64(j)*360/float64(point.EndCapSides)
-					outPoint(
-						point.X+point.Thickness/2*degCos(angle),
-						point.Y+point.Thickness/2*degSin(angle),
-						j == point.EndCapSides/2)
-				}
-				outNewLine()
-			} else if i == len(polygon.Points)-1 {
-				// Draw end cap
-				if len(polygon.Points) > 2 {
-					outNewLine()
-				}
-				headingEnd := polygon.Headings[i-1]
-				for j := 0; j <= point.EndCapSides/2; j++ {
-					angle := headingEnd + 90 - float64(j)*360/float64(point.EndCapSides)
-					outPoint(
-						point.X+point.Thickness/2*degCos(angle),
-						point.Y+point.Thickness/2*degSin(angle),
-						j == point.EndCapSides/2)
-				}
-				if len(polygon.Points) > 2 {
-					outNewLine()
-				}
-			} else {
-				// Join together two pen strokes
-				var headingPrev float64
-				var headingNext float64
-				if d == 1 {
-					headingPrev = polygon.Headings[i-1]
-					headingNext = polygon.Headings[i]
-				} else {
-					headingPrev = polygon.Headings[i]
-					headingNext = polygon.Headings[i-1]
-				}
-				isLastPoint :=
-					((i == len(polygon.Points)-2 && d == 1) || (i == 1 && d == -1))
-				if headingPrev == headingNext {
-					// Degenerate case: both segments being joined have the same
-					// heading.  The end of the current pen-stroke is the start
-					// of the next pen-stroke, no need to calculate more.
-					heading := headingPrev + float64(90*d)
-					outPoint(
-						point.X+point.Thickness/2*degCos(heading),
-						point.Y+point.Thickness/2*degSin(heading),
-						isLastPoint)
-				} else {
-					// Need to calculate the point marked with an 'x' in the
-					// diagram below, which is the intersection of the edges of
-					// the current pen-stroke (line between points 1-2) and the
-					// next pen-stroke (line between points 3-4):
-					//
-					//       / .  4
-					//   ----    /
-					//   .   .  /
-					//  1------x2
-					//        3
-					//
-					pointPrev := polygon.Points[i-d]
-					pointNext := polygon.Points[i+d]
-					headingEdgePrev := headingPrev + float64(90*d)
-					headingEdgeNext := headingNext + float64(90*d)
-					// Point 1
-					x1 := pointPrev.X + pointPrev.Thickness/2*degCos(headingEdgePrev)
-					y1 := pointPrev.Y + pointPrev.Thickness/2*degSin(headingEdgePrev)
-					// Point 2
-					x2 := point.X + point.Thickness/2*degCos(headingEdgePrev)
-					y2 := point.Y + point.Thickness/2*degSin(headingEdgePrev)
-					// Point 3
-					x3 := point.X + point.Thickness/2*degCos(headingEdgeNext)
-					y3 := point.Y + point.Thickness/2*degSin(headingEdgeNext)
-					// Point 4
-					x4 := pointNext.X + pointNext.Thickness/2*degCos(headingEdgeNext)
-					y4 := pointNext.Y + pointNext.Thickness/2*degSin(headingEdgeNext)
-					// Calculation
-					// https://en.wikipedia.org/wiki/Line%E2%80%93line_intersection#Given_two_points_on_each_line
-					denom := (x1-x2)*(y3-y4) - (y1-y2)*(x3-x4)
-					x := ((x1*y2-y1*x2)*(x3-x4) - (x1-x2)*(x3*y4-y3*x4)) / denom
-					y := ((x1*y2-y1*x2)*(y3-y4) - (y1-y2)*(x3*y4-y3*x4)) / denom
-					outPoint(x, y, isLastPoint)
-				}
+		bounds := "nothing drawn"
+		if stats.Bounds != nil {
+			bounds = fmt.Sprintf("bounds [%s, %s] to [%s, %s]",
+				formatFloat(stats.Bounds[0][0]), formatFloat(stats.Bounds[0][1]),
+				formatFloat(stats.Bounds[1][0]), formatFloat(stats.Bounds[1][1]))
+		}
+		log.New(stderr, "", log.LstdFlags).Printf("%s: %d polygons, %d points, %d segments, path length %s mm, %s, compiled in %s",
+			filename, stats.Polygons, stats.Points, stats.Segments,
+			formatFloat(stats.PathLength), bounds, stats.CompileTime.Round(time.Microsecond))
+	}
+	printProfile := func(stderr io.Writer, filename string, profile *compileProfile) {
+		if profile != nil {
+			if err := writeProfile(stderr, filename, profile); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	// writePreview draws the preview image, and warns about any parts of
+	// the output that it doesn't show properly
+	writePreview := func(stderr io.Writer, filename string, d *previewDrawing) error {
+		if err := writePreviewFile(args.Preview, d, args.PreviewGrid); err != nil {
+			return err
+		}
+		for _, block := range d.Ignored {
+			report(stderr, filename, "warning", diagnostic{
+				Message: "the preview ignores the effect of " + block,
+			})
+		}
+		return nil
+	}
+	checkWarnings := func() {
+		if strictFail && warnings > 0 {
+			// Each warning has already been printed as JSON
+			if args.JSONErrors {
+				os.Exit(1)
 			}
+			log.Fatalf("%d warning(s) found", warnings)
+		}
+	}
 
-			if i == len(polygon.Points)-1 && d == 1 {
-				d = -1
+	if args.OutputDir != "" {
+		if err := os.MkdirAll(args.OutputDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	outputFilename := func(filename string) string {
+		if args.OutputDir != "" {
+			return filepath.Join(args.OutputDir, filepath.Base(filename)+".scad")
+		}
+		return filename + ".scad"
+	}
+
+	if len(args.Filenames) == 1 && args.OutputDir == "" {
+		filename := args.Filenames[0]
+		opts := fileOptions(filename, os.Stderr)
+		output := bufio.NewWriter(os.Stdout)
+		err := compileFile(filename, output, opts, args.ValidateOpenscad)
+		if err == nil {
+			err = output.Flush()
+		}
+		if err == nil && opts.Preview != nil {
+			err = writePreview(os.Stderr, filename, opts.Preview)
+		}
+		if err != nil && !args.JSONErrors {
+			log.Fatal(err)
+		} else if err != nil {
+			reportError(os.Stderr, filename, err)
+			os.Exit(1)
+		}
+		printStats(os.Stderr, filename, opts.Stats)
+		printProfile(os.Stderr, filename, opts.Profile)
+		checkWarnings()
+		return
+	}
+
+	// Compile each file in parallel.  What each one prints to stderr is
+	// held until they have all finished, so that it comes out in the same
+	// order as the files.
+	errs := make([]error, len(args.Filenames))
+	stderrs := make([]bytes.Buffer, len(args.Filenames))
+	var wg sync.WaitGroup
+	for i, filename := range args.Filenames {
+		wg.Add(1)
+		go func(i int, filename string) {
+			defer wg.Done()
+			opts := fileOptions(filename, &stderrs[i])
+			errs[i] = compileFileToFile(filename, outputFilename(filename), opts, args.ValidateOpenscad)
+			if errs[i] == nil && opts.Preview != nil {
+				errs[i] = writePreview(&stderrs[i], filename, opts.Preview)
 			}
-			if i == 1 && d == -1 {
-				break
-			} else {
-				i += d
+			if errs[i] == nil {
+				printStats(&stderrs[i], filename, opts.Stats)
+				printProfile(&stderrs[i], filename, opts.Profile)
 			}
+		}(i, filename)
+	}
+	wg.Wait()
+
+	failed := false
+	for i, err := range errs {
+		os.Stderr.Write(stderrs[i].Bytes())
+		if err != nil {
+			reportError(os.Stderr, args.Filenames[i], err)
+			failed = true
 		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	checkWarnings()
+}
 
-		outEndPolygon()
-	}
-
-	// Strip hashbang line if present
-	jsInput = regexp.MustCompile(`^#!.*\n`).ReplaceAllString(jsInput, "\n")
-
-	// Set up JavaScript interpreter
-	vm = otto.New()
-
-	// Internal state variables
-	turtlePendown := false
-	var turtlePenSize float64 = 1
-	var turtleEndCapSides int = 60
-	var turtleX float64 = 0
-	var turtleY float64 = 0
-	var turtleHeading float64 = 0
-	var turtlePolygon TurtlePolygon
-
-	// Set up functions
-	vm.Set("pendown", func(call otto.FunctionCall) otto.Value {
-		if !turtlePendown {
-			turtlePendown = true
-			turtlePolygon = TurtlePolygon{
-				Points: []TurtlePoint{{
-					X:           turtleX,
-					Y:           turtleY,
-					Thickness:   turtlePenSize,
-					EndCapSides: turtleEndCapSides,
-				}},
-				Headings:  make([]float64, 0),
-				ZeroWidth: (turtlePenSize == 0),
-			}
+// parseFit parses the size given to --fit, which must be two positive
+// numbers separated by an x.
+func parseFit(value string) ([2]float64, error) {
+	var fit [2]float64
+	parts := strings.Split(value, "x")
+	if len(parts) != 2 {
+		return fit, errors.New("--fit must be given as WIDTHxHEIGHT, e.g. 100x50")
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || !(n > 0) || math.IsInf(n, 1) {
+			return fit, errors.New("--fit must be given as WIDTHxHEIGHT, e.g. 100x50, with positive numbers")
 		}
-		return otto.UndefinedValue()
-	})
-	vm.Set("penup", func(call otto.FunctionCall) otto.Value {
-		if turtlePendown {
-			turtlePendown = false
-			if len(turtlePolygon.Points) != len(turtlePolygon.Headings)+1 {
-				log.Fatalf("Bad polygon: points=%d headings=%d",
-					len(turtlePolygon.Points),
-					len(turtlePolygon.Headings))
+		fit[i] = n
+	}
+	return fit, nil
+}
+
+// compileFile compiles the given go-scad file, writing the resulting
+// OpenSCAD code to w.  Data files are loaded from the directory containing
+// the file.  If checkOpenscad is true, the code is only written if the
+// openscad binary accepts it.
+func compileFile(filename string, w io.Writer, opts compileOptions, checkOpenscad bool) error {
+	jsInputBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	opts.BaseDir = filepath.Dir(filename)
+	if !checkOpenscad {
+		out := &errWriter{w: w}
+		if err := compile(out, string(jsInputBytes), opts); err != nil {
+			if out.err != nil {
+				return out.err
 			}
-			writePolygon(turtlePolygon)
-		}
-		return otto.UndefinedValue()
-	})
-	vm.Set("pensize", func(call otto.FunctionCall) otto.Value {
-		if call.Argument(0).IsUndefined() {
-			return toJsValue(turtlePenSize)
-		}
-		turtlePenSize = toFloat(call.Argument(0))
-		if turtlePenSize < 0 {
-			log.Fatal("Pen size set to less than 0")
-		} else if turtlePendown && turtlePolygon.ZeroWidth && turtlePenSize > 0 {
-			log.Fatal("Polygon was started with pen size 0 and then set to non-zero")
-		} else if turtlePendown && !turtlePolygon.ZeroWidth && turtlePenSize == 0 {
-			log.Fatal("Polygon was started with non-zero pen size and then set to 0")
-		}
-		return otto.UndefinedValue()
-	})
-	vm.Set("end_cap_sides", func(call otto.FunctionCall) otto.Value {
-		if call.Argument(0).IsUndefined() {
-			return toJsValue(turtleEndCapSides)
-		}
-		turtleEndCapSides = toInt(call.Argument(0))
-		if turtleEndCapSides < 2 || turtleEndCapSides%2 == 1 {
-			log.Fatalf("Invalid end_cap_sides value: %d", turtleEndCapSides)
-		}
-		return otto.UndefinedValue()
-	})
-	vm.Set("forward", func(call otto.FunctionCall) otto.Value {
-		d := toFloat(call.Argument(0))
-		turtleX += d * degCos(turtleHeading)
-		turtleY += d * degSin(turtleHeading)
-		if turtlePendown {
-			turtlePolygon.Points = append(turtlePolygon.Points, TurtlePoint{
-				X:           turtleX,
-				Y:           turtleY,
-				Thickness:   turtlePenSize,
-				EndCapSides: turtleEndCapSides,
-			})
-			turtlePolygon.Headings = append(turtlePolygon.Headings, turtleHeading)
-		}
-		return otto.UndefinedValue()
-	})
-	vm.Set("right", func(call otto.FunctionCall) otto.Value {
-		turtleHeading -= toFloat(call.Argument(0))
-		return otto.UndefinedValue()
-	})
-	vm.Set("left", func(call otto.FunctionCall) otto.Value {
-		turtleHeading += toFloat(call.Argument(0))
-		return otto.UndefinedValue()
-	})
-	vm.Set("setpos", func(call otto.FunctionCall) otto.Value {
-		x := toFloat(call.Argument(0))
-		y := toFloat(call.Argument(1))
-		thisHeading := radToDeg(math.Atan2(y-turtleY, x-turtleX))
-		turtleX = x
-		turtleY = y
-		if turtlePendown {
-			turtlePolygon.Points = append(turtlePolygon.Points, TurtlePoint{
-				X:           turtleX,
-				Y:           turtleY,
-				Thickness:   turtlePenSize,
-				EndCapSides: turtleEndCapSides,
-			})
-			turtlePolygon.Headings = append(turtlePolygon.Headings, thisHeading)
-		}
-		return otto.UndefinedValue()
-	})
-	vm.Set("heading", func(call otto.FunctionCall) otto.Value {
-		return toJsValue(turtleHeading)
-	})
-	vm.Set("wrap", func(call otto.FunctionCall) otto.Value {
-		outBeginBlock(toString(call.Argument(0)))
-		call.Argument(1).Call(otto.UndefinedValue())
-		outEndBlock()
-		return otto.UndefinedValue()
-	})
-	vm.Set("echo", func(call otto.FunctionCall) otto.Value {
-		outEcho(toString(call.Argument(0)))
-		return otto.UndefinedValue()
-	})
-
-	// Set up aliases
-	vm.Run("pd = down = pendown;")
-	vm.Run("pu = up = penup;")
-	vm.Run("width = pensize;")
-	vm.Run("rt = right;")
-	vm.Run("lt = left;")
-	vm.Run("setposition = setpos;") // Note, no `goto` alias (reserved word)
-
-	// Run the script
-	_, err := vm.Run(jsInput)
+			return scriptError{err}
+		}
+		return nil
+	}
+
+	// OpenSCAD needs the whole output before any of it is written
+	output, err := jsToScad(string(jsInputBytes), opts)
+	if err != nil {
+		return scriptError{err}
+	}
+	if err := checkWithOpenscad(opts.BaseDir, output); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, output)
+	return err
+}
+
+// errWriter remembers the first error from writing to w, to tell it apart
+// from errors in the script.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// scriptError is returned by compileFile if the script fails.
+type scriptError struct {
+	err error
+}
+
+func (e scriptError) Error() string {
+	return formatError(e.err)
+}
+
+func compileFileToFile(filename string, outputFilename string, opts compileOptions, checkOpenscad bool) error {
+	f, err := os.Create(outputFilename)
+	if err != nil {
+		return err
+	}
+	output := bufio.NewWriter(f)
+	err = compileFile(filename, output, opts, checkOpenscad)
+	if err == nil {
+		err = output.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writePreviewFile draws a preview of a script's output into a PNG file.
+func writePreviewFile(filename string, d *previewDrawing, grid float64) error {
+	f, err := os.Create(filename)
 	if err != nil {
-		if jsErr, ok := err.(*otto.Error); ok {
-			log.Fatalf("JavaScript error: %s", jsErr.String())
-		} else {
-			log.Fatal("JavaScript error: ", err)
+		return err
+	}
+	output := bufio.NewWriter(f)
+	err = writePreview(output, d, grid)
+	if err == nil {
+		err = output.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readScriptFiles reads the source code of plugins or init scripts.
+func readScriptFiles(filenames []string) ([]scriptFile, error) {
+	var plugins []scriptFile
+	for _, filename := range filenames {
+		source, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
 		}
+		plugins = append(plugins, scriptFile{filename, string(source)})
 	}
+	return plugins, nil
+}
 
-	return output
+func emitDts(filename string, plugins []scriptFile) {
+	s := newScript(compileOptions{Plugins: plugins})
+	if err := s.runPreludes(); err != nil {
+		log.Fatal(formatError(err))
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = writeDts(f, s.builtins)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
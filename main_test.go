@@ -3,10 +3,13 @@ package main
 import (
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
@@ -26,47 +29,134 @@ func TestIntegration(t *testing.T) {
 			log.Fatal(err)
 		}
 		if matched {
-			t.Run(f.Name(), func(t *testing.T) {
-				testSingleFile(t, filepath.Join(testDir, f.Name()))
+			name := f.Name()
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				testSingleFile(t, filepath.Join(testDir, name))
 			})
 		}
 	}
 }
 
-func readFile(t *testing.T, filename string) string {
-	bytes, err := ioutil.ReadFile(filename)
-	if err != nil {
-		t.Log(err)
+func testSingleFile(t *testing.T, testFilePath string) {
+	result := runGoldenTest(testFilePath, compileOptions{}, os.Getenv("REGENERATE_OUTPUT") != "")
+	if result.Status == "error" {
+		t.Log(result.Error)
 		t.FailNow()
 	}
-	return string(bytes)
+	dmp := diffmatchpatch.New()
+	for _, name := range sortedDiffNames(result) {
+		t.Error("output doesn't match " + name + ":\n" +
+			"\x1b[31m- actual\x1b[0m \x1b[32m+ expected\x1b[0m\n" +
+			dmp.DiffPrettyText(result.Diffs[name]))
+	}
 }
 
-func testSingleFile(t *testing.T, testFilePath string) {
-	// Read input file
-	inputBytes := readFile(t, testFilePath)
+func TestCompactOutput(t *testing.T) {
+	input := "end_cap_sides(4); hollow(0.1); pendown(); forward(1); penup();"
+	output, err := jsToScad(input, compileOptions{Compact: true})
+	if err != nil {
+		t.Fatal(formatError(err))
+	}
+	expected := "difference() {\n" +
+		"polygon(points = [[0,-0.5],[-0.5,0],[0,0.5],[1,0.5],[1.5,0],[1,-0.5],]);\n" +
+		"offset(delta = -0.1) {\n" +
+		"polygon(points = [[0,-0.5],[-0.5,0],[0,0.5],[1,0.5],[1.5,0],[1,-0.5],]);\n" +
+		"}\n" +
+		"}\n"
+	if output != expected {
+		t.Errorf("compact output doesn't match:\n%s", output)
+	}
+}
 
-	// Process it
-	output := jsToScad(inputBytes)
+func TestPreview(t *testing.T) {
+	preview := &previewDrawing{}
+	_, err := jsToScad("pensize(2); pendown(); forward(10); penup();", compileOptions{Preview: preview})
+	if err != nil {
+		t.Fatal(formatError(err))
+	}
+	img := renderPreview(preview, 1)
+	if size := img.Bounds().Size(); size.X != previewSize {
+		t.Errorf("preview is %d pixels wide, expected %d", size.X, previewSize)
+	}
+	center := img.Bounds().Size().Div(2)
+	if c := img.RGBAAt(center.X, center.Y); c != previewFillColor {
+		t.Errorf("middle of the line is %v, expected %v", c, previewFillColor)
+	}
+	if c := img.RGBAAt(1, 1); c != previewBackground {
+		t.Errorf("corner is %v, expected %v", c, previewBackground)
+	}
+}
 
-	// Optional: Write output file
-	if os.Getenv("REGENERATE_OUTPUT") != "" {
-		err := ioutil.WriteFile(testFilePath+".scad", []byte(output), 0644)
-		if err != nil {
-			t.Log(err)
-			t.FailNow()
+func TestCalligraphyErrors(t *testing.T) {
+	tests := map[string]string{
+		"calligraphy(0, 45);":                  "Calligraphy pen width must be greater than 0, not 0",
+		"calligraphy(-2, 45);":                 "Calligraphy pen width must be greater than 0, not -2",
+		"calligraphy(2, 45, -0.5);":            "Calligraphy pen thickness must be positive (or 0 for a flat nib), not -0.5",
+		"units('cm'); calligraphy(2, 45, -1);": "Calligraphy pen thickness must be positive (or 0 for a flat nib), not -1",
+	}
+	for input, expected := range tests {
+		_, err := jsToScad(input, compileOptions{})
+		if err == nil || !strings.Contains(formatError(err), expected) {
+			t.Errorf("%s: returned %v, expected %q", input, err, expected)
 		}
 	}
+	if _, err := jsToScad("calligraphy(2, 45, 0); pendown(); forward(1); penup();", compileOptions{}); err != nil {
+		t.Errorf("flat nib: %s", formatError(err))
+	}
+}
+
+func TestPreviewTransforms(t *testing.T) {
+	preview := &previewDrawing{}
+	input := "end_cap_sides(4);\n" +
+		"shear(1, 0, function() { setpos(0, 1); pendown(); forward(2); penup(); });\n" +
+		"matrix([[0, -1, 0, 10], [1, 0, 0, 0], [0, 0, 1, 0]], function() { setpos(0, 0); pendown(); forward(1); penup(); });\n" +
+		"wrap('rotate(45)', function() { pendown(); forward(1); penup(); });\n" +
+		"scad('linear_extrude', {height: 2}, function() { wrap('rotate(45)', function() {}); });\n"
+	if _, err := jsToScad(input, compileOptions{Preview: preview}); err != nil {
+		t.Fatal(formatError(err))
+	}
+	expected := [][][2]float64{
+		// Sheared, so that each point moves along x by its y coordinate
+		{{0.5, 0.5}, {0.5, 1}, {1.5, 1.5}, {3.5, 1.5}, {3.5, 1}, {2.5, 0.5}},
+		// Rotated by 90 degrees and moved along x
+		{{10.5, 0}, {10, -0.5}, {9.5, 0}, {9.5, 1}, {10, 1.5}, {10.5, 1}},
+		// Drawn as if the wrapper weren't there
+		{{1, -0.5}, {0.5, 0}, {1, 0.5}, {2, 0.5}, {2.5, 0}, {2, -0.5}},
+	}
+	for _, shape := range preview.Shapes {
+		for i := range shape {
+			for j := range shape[i] {
+				shape[i][j] = math.Round(shape[i][j]*1e6) / 1e6
+			}
+		}
+	}
+	if !reflect.DeepEqual(preview.Shapes, expected) {
+		t.Errorf("preview shapes are %v, expected %v", preview.Shapes, expected)
+	}
+	if ignored := []string{"rotate(45)", "linear_extrude(height = 2)"}; !reflect.DeepEqual(preview.Ignored, ignored) {
+		t.Errorf("ignored blocks are %q, expected %q", preview.Ignored, ignored)
+	}
+}
 
-	// Read expected output
-	expectedOutput := readFile(t, testFilePath+".scad")
+func TestParseCsvPoints(t *testing.T) {
+	points, err := parseCsvPoints(strings.NewReader("x,y\n1,2\n\n# comment\n3, 4.5,extra\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][2]float64{{1, 2}, {3, 4.5}}; !reflect.DeepEqual(points, expected) {
+		t.Errorf("points are %v, expected %v", points, expected)
+	}
 
-	// Compare
-	if output != expectedOutput {
-		dmp := diffmatchpatch.New()
-		diffs := dmp.DiffMain(output, expectedOutput, false)
-		t.Error("output doesn't match " + filepath.Base(testFilePath) + ":\n" +
-			"\x1b[31m- actual\x1b[0m \x1b[32m+ expected\x1b[0m\n" +
-			dmp.DiffPrettyText(diffs))
+	tests := map[string]string{
+		"1,2\nfoo,5\n6,7\n": `line 2: expected numbers in the first 2 columns: "foo,5"`,
+		"x,y\n1,2\n\n3,y\n": `line 4: expected numbers in the first 2 columns: "3,y"`,
+		"x,y\nx,y\n":        `line 2: expected numbers in the first 2 columns: "x,y"`,
+	}
+	for input, expected := range tests {
+		_, err := parseCsvPoints(strings.NewReader(input))
+		if err == nil || err.Error() != expected {
+			t.Errorf("%q: returned %v, expected %q", input, err, expected)
+		}
 	}
 }
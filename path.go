@@ -0,0 +1,56 @@
+package main
+
+// recordedPath holds the lines drawn inside a call to record(), so that they
+// can be transformed and drawn again.
+type recordedPath struct {
+	Strokes [][]TurtlePoint
+}
+
+// transform returns a copy of the path with fn applied to each point.
+func (p *recordedPath) transform(fn func(x, y float64) (float64, float64)) *recordedPath {
+	result := &recordedPath{Strokes: make([][]TurtlePoint, len(p.Strokes))}
+	for i, stroke := range p.Strokes {
+		result.Strokes[i] = make([]TurtlePoint, len(stroke))
+		for j, point := range stroke {
+			point.X, point.Y = fn(point.X, point.Y)
+			result.Strokes[i][j] = point
+		}
+	}
+	return result
+}
+
+func (p *recordedPath) translate(dx, dy float64) *recordedPath {
+	return p.transform(func(x, y float64) (float64, float64) {
+		return x + dx, y + dy
+	})
+}
+
+// rotate rotates the path counterclockwise around the origin.
+func (p *recordedPath) rotate(angle float64) *recordedPath {
+	cos, sin := degCos(angle), degSin(angle)
+	return p.transform(func(x, y float64) (float64, float64) {
+		return x*cos - y*sin, x*sin + y*cos
+	})
+}
+
+// scale scales the path's coordinates relative to the origin.  The
+// thickness of its lines is not changed.
+func (p *recordedPath) scale(sx, sy float64) *recordedPath {
+	return p.transform(func(x, y float64) (float64, float64) {
+		return x * sx, y * sy
+	})
+}
+
+// reverse returns a copy of the path which is drawn in the opposite order.
+func (p *recordedPath) reverse() *recordedPath {
+	n := len(p.Strokes)
+	result := &recordedPath{Strokes: make([][]TurtlePoint, n)}
+	for i, stroke := range p.Strokes {
+		reversed := make([]TurtlePoint, len(stroke))
+		for j, point := range stroke {
+			reversed[len(stroke)-1-j] = point
+		}
+		result.Strokes[n-1-i] = reversed
+	}
+	return result
+}
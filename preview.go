@@ -0,0 +1,200 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+// previewSize is the width or height of preview images in pixels,
+// whichever is larger, and previewMargin is the space around the drawing.
+const (
+	previewSize   = 800
+	previewMargin = 16
+)
+
+// previewSamples is the number of samples taken across and down each pixel
+// to smooth the edges of shapes in preview images.
+const previewSamples = 4
+
+var (
+	previewBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	previewGridColor  = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	previewAxisColor  = color.RGBA{0xaa, 0xaa, 0xaa, 0xff}
+	previewFillColor  = color.RGBA{0x33, 0x66, 0x99, 0xff}
+)
+
+// previewDrawing holds the shapes in the output of a script as seen from
+// above, for drawing a preview image without OpenSCAD.
+type previewDrawing struct {
+	// Shapes holds the outline of each polygon in the output, in
+	// millimeters.  The shapes are filled in where they overlap.
+	Shapes [][][2]float64
+	// Ignored lists the blocks written by wrap() and scad() around shapes
+	// in the output, such as "rotate(45)".  The preview draws the shapes
+	// as if they weren't there.
+	Ignored []string
+}
+
+// renderPreview draws the shapes in a drawing, filled in and with smooth
+// edges, scaled to fit into an image previewSize pixels wide or high.  If
+// grid is non-zero, grid lines are drawn behind the shapes this many
+// millimeters apart, with darker lines along the axes.
+func renderPreview(d *previewDrawing, grid float64) *image.RGBA {
+	bounds := newBoundingBox()
+	for _, shape := range d.Shapes {
+		for _, point := range shape {
+			bounds.add(point[0], point[1])
+		}
+	}
+	if bounds.Empty {
+		bounds.add(-1, -1)
+		bounds.add(1, 1)
+	}
+	size := [2]float64{bounds.Max[0] - bounds.Min[0], bounds.Max[1] - bounds.Min[1]}
+	scale := float64(previewSize-2*previewMargin) / math.Max(math.Max(size[0], size[1]), 1e-9)
+	width := int(math.Ceil(size[0]*scale)) + 2*previewMargin
+	height := int(math.Ceil(size[1]*scale)) + 2*previewMargin
+
+	// Converts millimeters to pixels, with the y axis pointing down
+	toPixels := func(point [2]float64) [2]float64 {
+		return [2]float64{
+			(point[0]-bounds.Min[0])*scale + previewMargin,
+			(bounds.Max[1]-point[1])*scale + previewMargin,
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{previewBackground}, image.Point{}, draw.Src)
+	// Grid lines closer than a few pixels apart would hide the drawing
+	if grid*scale >= 4 {
+		lineColor := func(i int) color.RGBA {
+			if i == 0 {
+				return previewAxisColor
+			}
+			return previewGridColor
+		}
+		margin := previewMargin / scale
+		for i := int(math.Ceil((bounds.Min[0] - margin) / grid)); float64(i)*grid <= bounds.Max[0]+margin; i++ {
+			x := int(toPixels([2]float64{float64(i) * grid, 0})[0])
+			for y := 0; y < height && x >= 0 && x < width; y++ {
+				img.SetRGBA(x, y, lineColor(i))
+			}
+		}
+		for i := int(math.Ceil((bounds.Min[1] - margin) / grid)); float64(i)*grid <= bounds.Max[1]+margin; i++ {
+			y := int(toPixels([2]float64{0, float64(i) * grid})[1])
+			for x := 0; x < width && y >= 0 && y < height; x++ {
+				img.SetRGBA(x, y, lineColor(i))
+			}
+		}
+	}
+
+	// Convert the shapes to pixels, and find which rows each one covers
+	type pixelShape struct {
+		points     [][2]float64
+		minY, maxY float64
+	}
+	shapes := make([]pixelShape, 0, len(d.Shapes))
+	for _, shape := range d.Shapes {
+		if len(shape) < 3 {
+			continue
+		}
+		converted := pixelShape{minY: math.Inf(1), maxY: math.Inf(-1)}
+		for _, point := range shape {
+			point = toPixels(point)
+			converted.points = append(converted.points, point)
+			converted.minY = math.Min(converted.minY, point[1])
+			converted.maxY = math.Max(converted.maxY, point[1])
+		}
+		shapes = append(shapes, converted)
+	}
+
+	// Count the samples inside any shape in each pixel.  Each row of
+	// samples is filled where the outline of a shape winds around it.
+	coverage := make([]int, width*height)
+	inside := make([]bool, width*previewSamples)
+	type crossing struct {
+		x         float64
+		direction int
+	}
+	var crossings []crossing
+	for sy := 0; sy < height*previewSamples; sy++ {
+		y := (float64(sy) + 0.5) / previewSamples
+		for i := range inside {
+			inside[i] = false
+		}
+		for _, shape := range shapes {
+			if y < shape.minY || y > shape.maxY {
+				continue
+			}
+			crossings = crossings[:0]
+			for i, p1 := range shape.points {
+				p2 := shape.points[(i+1)%len(shape.points)]
+				if (p1[1] <= y) == (p2[1] <= y) {
+					continue
+				}
+				direction := 1
+				if p2[1] < p1[1] {
+					direction = -1
+				}
+				x := p1[0] + (y-p1[1])*(p2[0]-p1[0])/(p2[1]-p1[1])
+				crossings = append(crossings, crossing{x, direction})
+			}
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+			winding := 0
+			for i, c := range crossings {
+				winding += c.direction
+				if winding == 0 || i == len(crossings)-1 {
+					continue
+				}
+				// Fill the samples between this crossing and the next
+				start := int(math.Ceil(c.x*previewSamples - 0.5))
+				end := int(math.Ceil(crossings[i+1].x*previewSamples - 0.5))
+				if start < 0 {
+					start = 0
+				}
+				if end > len(inside) {
+					end = len(inside)
+				}
+				for sx := start; sx < end; sx++ {
+					inside[sx] = true
+				}
+			}
+		}
+		row := sy / previewSamples * width
+		for sx, filled := range inside {
+			if filled {
+				coverage[row+sx/previewSamples]++
+			}
+		}
+	}
+
+	// Blend the fill color into each pixel by how much of it is covered
+	for i, count := range coverage {
+		if count == 0 {
+			continue
+		}
+		alpha := float64(count) / (previewSamples * previewSamples)
+		x, y := i%width, i/width
+		under := img.RGBAAt(x, y)
+		blend := func(a, b uint8) uint8 {
+			return uint8(math.Round(float64(a)*(1-alpha) + float64(b)*alpha))
+		}
+		img.SetRGBA(x, y, color.RGBA{
+			blend(under.R, previewFillColor.R),
+			blend(under.G, previewFillColor.G),
+			blend(under.B, previewFillColor.B),
+			0xff,
+		})
+	}
+	return img
+}
+
+// writePreview draws a preview of a drawing as a PNG image.
+func writePreview(w io.Writer, d *previewDrawing, grid float64) error {
+	return png.Encode(w, renderPreview(d, grid))
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// profileInterval is how often the line that a script is running is
+// sampled when profiling.
+const profileInterval = time.Millisecond
+
+// profileStages lists the stages of compiling a script that are timed when
+// profiling, in the order they are reported.
+var profileStages = []struct{ Name, Description string }{
+	{"script", "running the script, including the next three stages"},
+	{"strokes", "computing the outlines of lines"},
+	{"formatting", "writing polygons"},
+	{"checks", "--validate and --strict checks"},
+	{"output", "writing the output"},
+}
+
+// compileProfile records where the time is spent while compiling a script.
+type compileProfile struct {
+	// Stages gives the time spent in each of profileStages.
+	Stages map[string]time.Duration
+	// Samples counts how many times the script was sampled, and Lines and
+	// Functions count the samples where it was running each line and each
+	// function.  Time spent in go-scad's functions is counted for the line
+	// that runs after they return.
+	Samples   int
+	Lines     map[profileLine]int
+	Functions map[string]int
+}
+
+// profileLine is a line of a script or plugin, and the function it is in.
+type profileLine struct {
+	File     string
+	Line     int
+	Function string
+}
+
+func newCompileProfile() *compileProfile {
+	return &compileProfile{
+		Stages:    map[string]time.Duration{},
+		Lines:     map[profileLine]int{},
+		Functions: map[string]int{},
+	}
+}
+
+// stage starts timing one of profileStages, and returns a function which
+// adds the time since then to it.  If p is nil, nothing is timed.
+func (p *compileProfile) stage(name string) func() {
+	if p == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		p.Stages[name] += time.Since(start)
+	}
+}
+
+// sample records the line and function that the script is running.  It
+// must be called from the goroutine running the script.
+func (p *compileProfile) sample(vm *otto.Otto) {
+	context := vm.Context()
+	function := context.Callee
+	if function == "" {
+		function = "(top level)"
+	}
+	file := scriptFilename(context.Filename)
+	p.Samples++
+	p.Lines[profileLine{file, context.Line, function}]++
+	if file != "" {
+		function += " (" + file + ")"
+	}
+	p.Functions[function]++
+}
+
+// startSampling samples the script running in vm every profileInterval until
+// the returned function is called.  vm.Interrupt must not be nil.
+func (p *compileProfile) startSampling(vm *otto.Otto) func() {
+	interrupt := vm.Interrupt
+	ticker := time.NewTicker(profileInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				// Skip the sample if the last one hasn't been taken yet
+				select {
+				case interrupt <- func() { p.sample(vm) }:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// writeProfile writes a report of where the time was spent compiling a
+// file, listing the lines and functions with the most samples.
+func writeProfile(w io.Writer, filename string, p *compileProfile) error {
+	const top = 10
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s: profile\n", filename)
+	for _, stage := range profileStages {
+		fmt.Fprintf(&out, "  %-10s %10s  %s\n", stage.Name,
+			p.Stages[stage.Name].Round(time.Microsecond), stage.Description)
+	}
+
+	percent := func(samples int) float64 {
+		return float64(samples) * 100 / float64(p.Samples)
+	}
+	fmt.Fprintf(&out, "  %d samples, every %s\n", p.Samples, profileInterval)
+	if p.Samples > 0 {
+		lines := make([]profileLine, 0, len(p.Lines))
+		for line := range p.Lines {
+			lines = append(lines, line)
+		}
+		sort.Slice(lines, func(i, j int) bool {
+			if p.Lines[lines[i]] != p.Lines[lines[j]] {
+				return p.Lines[lines[i]] > p.Lines[lines[j]]
+			}
+			if lines[i].File != lines[j].File {
+				return lines[i].File < lines[j].File
+			}
+			return lines[i].Line < lines[j].Line
+		})
+		out.WriteString("  lines:\n")
+		for i, line := range lines {
+			if i == top {
+				break
+			}
+			file := line.File
+			if file == "" {
+				file = filename
+			}
+			fmt.Fprintf(&out, "  %8d %5.1f%%  %s:%d in %s\n", p.Lines[line],
+				percent(p.Lines[line]), file, line.Line, line.Function)
+		}
+
+		functions := make([]string, 0, len(p.Functions))
+		for function := range p.Functions {
+			functions = append(functions, function)
+		}
+		sort.Slice(functions, func(i, j int) bool {
+			if p.Functions[functions[i]] != p.Functions[functions[j]] {
+				return p.Functions[functions[i]] > p.Functions[functions[j]]
+			}
+			return functions[i] < functions[j]
+		})
+		out.WriteString("  functions:\n")
+		for i, function := range functions {
+			if i == top {
+				break
+			}
+			fmt.Fprintf(&out, "  %8d %5.1f%%  %s\n", p.Functions[function],
+				percent(p.Functions[function]), function)
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,69 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteProfile(t *testing.T) {
+	p := newCompileProfile()
+	p.Stages["script"] = 30 * time.Millisecond
+	p.Stages["output"] = 1500 * time.Microsecond
+	p.Samples = 4
+	p.Lines[profileLine{"", 3, "spiral"}] = 3
+	p.Lines[profileLine{"plugin.js", 1, "(top level)"}] = 1
+	p.Functions["spiral"] = 3
+	p.Functions["(top level) (plugin.js)"] = 1
+	var out strings.Builder
+	if err := writeProfile(&out, "main.js", p); err != nil {
+		t.Fatal(err)
+	}
+	expected := "main.js: profile\n" +
+		"  script           30ms  running the script, including the next three stages\n" +
+		"  strokes            0s  computing the outlines of lines\n" +
+		"  formatting         0s  writing polygons\n" +
+		"  checks             0s  --validate and --strict checks\n" +
+		"  output          1.5ms  writing the output\n" +
+		"  4 samples, every 1ms\n" +
+		"  lines:\n" +
+		"         3  75.0%  main.js:3 in spiral\n" +
+		"         1  25.0%  plugin.js:1 in (top level)\n" +
+		"  functions:\n" +
+		"         3  75.0%  spiral\n" +
+		"         1  25.0%  (top level) (plugin.js)\n"
+	if out.String() != expected {
+		t.Errorf("profile:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestProfileFlag(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"busy.js": "end_cap_sides(4);\n" +
+			"function busy() {\n" +
+			"\tvar start = Date.now();\n" +
+			"\twhile (Date.now() - start < 50) {}\n" +
+			"}\n" +
+			"busy();\n" +
+			"pendown(); forward(1); penup();\n",
+	})
+	result := runCommand(t, dir, "", "--profile", "busy.js")
+	if result.status != 0 || !strings.HasPrefix(result.stdout, "polygon(") {
+		t.Fatalf("exit status %d, stdout %q, stderr %q", result.status, result.stdout, result.stderr)
+	}
+	for _, pattern := range []string{
+		`^busy\.js: profile\n`,
+		`\n  script +\S+  running the script`,
+		`\n  output +\S+  writing the output\n`,
+		`\n  \d+ samples, every 1ms\n  lines:\n +\d+ +\d+\.\d%  busy\.js:4 in busy\n`,
+		`\n  functions:\n +\d+ +\d+\.\d%  busy\n`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(result.stderr) {
+			t.Errorf("profile doesn't match %q:\n%s", pattern, result.stderr)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+type renderArgs struct {
+	globalArgs
+	Filename string `arg:"positional,required" help:"JavaScript input file"`
+	Output   string `arg:"-o,required" help:"file to render the model into, in a format chosen by OpenSCAD from its extension, such as .stl for 3D models or .svg for 2D drawings"`
+}
+
+func (renderArgs) Description() string {
+	return ("Compiles a go-scad script and renders the output with the" +
+		" openscad binary into any format that OpenSCAD can export.")
+}
+
+func render(argv []string) {
+	var args renderArgs
+	p := parseArgs("go-scad render", argv, &args)
+	opts, _ := args.load(p)
+
+	var scad strings.Builder
+	if err := compileFile(args.Filename, &scad, opts, false); err != nil {
+		log.Fatalf("%s: %s", args.Filename, err)
+	}
+	if err := renderWithOpenscad(filepath.Dir(args.Filename), scad.String(), args.Output); err != nil {
+		log.Fatal(err)
+	}
+}
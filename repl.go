@@ -0,0 +1,94 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"github.com/robertkrimen/otto/parser"
+
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+type replArgs struct {
+	globalArgs
+	Output string `help:"also write all OpenSCAD code generated so far to this file after each command"`
+}
+
+func (replArgs) Description() string {
+	return ("Runs go-scad commands interactively, printing the OpenSCAD code" +
+		" generated by each one.  Note that a line is not written until the" +
+		" pen is lifted.")
+}
+
+func repl(argv []string) {
+	var args replArgs
+	p := parseArgs("go-scad repl", argv, &args)
+	opts, _ := args.load(p)
+
+	baseDir, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.BaseDir = baseDir
+	s := newScript(opts)
+	if err := s.runPreludes(); err != nil {
+		log.Fatal(formatError(err))
+	}
+
+	if err := runRepl(s, os.Stdin, os.Stdout, args.Output); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runRepl reads commands from r and runs them in s, writing the OpenSCAD
+// code generated by each one to w.  If output is not empty, all of the code
+// generated so far is written to this file after each command.
+func runRepl(s *script, r io.Reader, w io.Writer, output string) error {
+	scanner := bufio.NewScanner(r)
+	input := ""
+	fmt.Fprint(w, "> ")
+	for scanner.Scan() {
+		input += scanner.Text() + "\n"
+
+		// Keep reading if the input so far is incomplete (for example, a
+		// function whose closing brace hasn't been typed yet)
+		_, err := parser.ParseFile(nil, "", input, 0)
+		if err != nil && strings.Contains(err.Error(), "Unexpected end of input") {
+			fmt.Fprint(w, "... ")
+			continue
+		}
+
+		// Print the code generated by each command
+		printed := s.body.Len()
+		value, err := s.run(input)
+		fmt.Fprint(w, s.body.String()[printed:])
+		if err != nil {
+			fmt.Fprintln(w, formatError(err))
+		} else if !value.IsUndefined() {
+			fmt.Fprintln(w, value.String())
+		}
+		input = ""
+
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			err = s.writeOutput(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(w, "> ")
+	}
+	fmt.Fprintln(w)
+	return scanner.Err()
+}
@@ -0,0 +1,61 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-scad-repl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outputFile := filepath.Join(dir, "output.scad")
+
+	s := newScript(compileOptions{Defines: map[string]interface{}{"width": 5}})
+	if err := s.runPreludes(); err != nil {
+		t.Fatal(formatError(err))
+	}
+	input := strings.Join([]string{
+		"width",
+		"width = 2;",
+		"width",
+		"function line(length) {",
+		"\tpendown(); forward(length); penup();",
+		"}",
+		"end_cap_sides(4); line(width)",
+		"nonexistent()",
+	}, "\n") + "\n"
+	var w strings.Builder
+	if err := runRepl(s, strings.NewReader(input), &w, outputFile); err != nil {
+		t.Fatal(err)
+	}
+	line := "polygon(points = [\n" +
+		"\t[0,-0.5], [-0.5,0], [0,0.5],\n" +
+		"\t[2,0.5], [2.5,0], [2,-0.5],\n" +
+		"]);\n"
+	expected := "> 5\n" +
+		"> 2\n" +
+		"> 2\n" +
+		"> ... ... > " + line +
+		"> JavaScript error: ReferenceError: 'nonexistent' is not defined\n" +
+		"    at <anonymous>:1:1\n\n" +
+		"> \n"
+	if w.String() != expected {
+		t.Errorf("REPL printed:\n%s\nexpected:\n%s", w.String(), expected)
+	}
+	output, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != line {
+		t.Errorf("output file has:\n%s\nexpected:\n%s", output, line)
+	}
+}
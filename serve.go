@@ -0,0 +1,129 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+type serveArgs struct {
+	globalArgs
+	Listen        string        `help:"address to listen on"`
+	Timeout       time.Duration `help:"maximum compile time per request"`
+	MaxConcurrent int           `arg:"--max-concurrent" help:"maximum number of scripts to compile at the same time"`
+	MaxPending    int           `arg:"--max-pending" help:"maximum number of requests being compiled or waiting to be compiled"`
+	MaxSize       int64         `arg:"--max-size" help:"maximum request size in bytes"`
+	CacheSize     int           `arg:"--cache-size" help:"number of compiled scripts to remember (0 to disable caching)"`
+}
+
+func (serveArgs) Description() string {
+	return ("Runs an HTTP server which compiles go-scad code.  POST the" +
+		" JavaScript source to /compile; the response is the OpenSCAD code," +
+		" or a JSON object like {\"errors\": [...]} if compilation failed.")
+}
+
+// compileServer handles compilation requests in server mode.
+type compileServer struct {
+	args serveArgs
+	// opts holds the options for compiling each script.
+	opts  compileOptions
+	cache *compileCache
+	// pending limits the number of requests being compiled or waiting to
+	// be compiled.
+	pending chan struct{}
+	// running limits the number of scripts being compiled.
+	running chan struct{}
+}
+
+func serve(argv []string) {
+	args := serveArgs{
+		Listen:        ":8080",
+		Timeout:       10 * time.Second,
+		MaxConcurrent: runtime.NumCPU(),
+		MaxPending:    16,
+		MaxSize:       1 << 20,
+		CacheSize:     64,
+	}
+	parser := parseArgs("go-scad serve", argv, &args)
+	if args.MaxConcurrent < 1 {
+		parser.Fail("--max-concurrent must be at least 1")
+	} else if args.MaxPending < args.MaxConcurrent {
+		parser.Fail("--max-pending must be at least --max-concurrent")
+	} else if args.CacheSize < 0 {
+		parser.Fail("--cache-size must not be negative")
+	}
+
+	opts, _ := args.load(parser)
+	opts.Timeout = args.Timeout
+	http.Handle("/compile", newCompileServer(args, opts))
+	log.Printf("Listening on %s", args.Listen)
+	log.Fatal(http.ListenAndServe(args.Listen, nil))
+}
+
+func newCompileServer(args serveArgs, opts compileOptions) *compileServer {
+	return &compileServer{
+		args:    args,
+		opts:    opts,
+		cache:   newCompileCache(args.CacheSize),
+		pending: make(chan struct{}, args.MaxPending),
+		running: make(chan struct{}, args.MaxConcurrent),
+	}
+}
+
+func (s *compileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	jsInputBytes, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, s.args.MaxSize))
+	if err != nil {
+		writeErrors(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+
+	jsInput := string(jsInputBytes)
+	opts := s.opts
+	if output, ok := s.cache.lookup(jsInput, opts); ok {
+		writeOutput(w, output)
+		return
+	}
+
+	select {
+	case s.pending <- struct{}{}:
+		defer func() { <-s.pending }()
+	default:
+		writeErrors(w, http.StatusServiceUnavailable, "Too many pending requests")
+		return
+	}
+
+	s.running <- struct{}{}
+	output, err := s.cache.compile(jsInput, opts)
+	<-s.running
+
+	if err == errTimeout {
+		writeErrors(w, http.StatusGatewayTimeout, formatError(err))
+	} else if err != nil {
+		writeErrors(w, http.StatusUnprocessableEntity, formatError(err))
+	} else {
+		writeOutput(w, output)
+	}
+}
+
+func writeOutput(w http.ResponseWriter, output string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(output))
+}
+
+func writeErrors(w http.ResponseWriter, status int, errors ...string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": errors})
+}
@@ -0,0 +1,105 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer() *compileServer {
+	return newCompileServer(serveArgs{
+		Timeout:       time.Second,
+		MaxConcurrent: 1,
+		MaxPending:    1,
+		MaxSize:       64,
+		CacheSize:     4,
+	}, compileOptions{Timeout: time.Second})
+}
+
+func postScript(server *compileServer, script string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/compile", strings.NewReader(script)))
+	return recorder
+}
+
+func TestServeCompile(t *testing.T) {
+	server := newTestServer()
+	response := postScript(server, "pendown(); forward(5); penup();")
+	if response.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", response.Code, response.Body)
+	}
+	if contentType := response.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type is %q", contentType)
+	}
+	if !strings.HasPrefix(response.Body.String(), "polygon(points = [") {
+		t.Errorf("unexpected output:\n%s", response.Body)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	tests := []struct {
+		name, method, script string
+		status               int
+		body                 string
+	}{{
+		name:   "script error",
+		method: http.MethodPost,
+		script: "nonexistent();",
+		status: http.StatusUnprocessableEntity,
+		body:   `{"errors":["JavaScript error: ReferenceError: 'nonexistent' is not defined`,
+	}, {
+		name:   "oversized body",
+		method: http.MethodPost,
+		script: strings.Repeat("forward(1);", 10),
+		status: http.StatusRequestEntityTooLarge,
+		body:   `{"errors":["http: request body too large"]}`,
+	}, {
+		name:   "wrong method",
+		method: http.MethodGet,
+		status: http.StatusMethodNotAllowed,
+		body:   `{"errors":["Method not allowed"]}`,
+	}}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(test.method, "/compile", strings.NewReader(test.script))
+		newTestServer().ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status %d, expected %d", test.name, recorder.Code, test.status)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("%s: Content-Type is %q", test.name, contentType)
+		}
+		if !strings.HasPrefix(recorder.Body.String(), test.body) {
+			t.Errorf("%s: body %q, expected it to start with %q", test.name, recorder.Body, test.body)
+		}
+	}
+}
+
+func TestServePending(t *testing.T) {
+	server := newTestServer()
+	cached := "pendown(); forward(5); penup();"
+	if response := postScript(server, cached); response.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", response.Code, response.Body)
+	}
+
+	// Fill the queue, as if another request were waiting
+	server.pending <- struct{}{}
+	defer func() { <-server.pending }()
+	response := postScript(server, "pendown(); forward(6); penup();")
+	if response.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, expected %d", response.Code, http.StatusServiceUnavailable)
+	}
+	if body := response.Body.String(); body != "{\"errors\":[\"Too many pending requests\"]}\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	// Cached scripts are still returned, since they don't need compiling
+	if response := postScript(server, cached); response.Code != http.StatusOK {
+		t.Errorf("cached script: status %d: %s", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lines.js": "end_cap_sides(4);\n" +
+			"pendown(); forward(10); left(90); forward(5); penup();\n" +
+			"jump(0, 10); pendown(); forward(2); penup();\n",
+		"empty.js": "forward(1);",
+	})
+	compileTime := regexp.MustCompile(`compiled in \S+\n$`)
+	tests := []struct {
+		filename, stderr string
+		polygons         int
+	}{
+		{"lines.js", "lines.js: 2 polygons, 14 points, 3 segments, path length 17 mm, " +
+			"bounds [-0.5, -0.5] to [10.5, 17.5], compiled in X\n", 2},
+		{"empty.js", "empty.js: 0 polygons, 0 points, 0 segments, path length 0 mm, " +
+			"nothing drawn, compiled in X\n", 0},
+	}
+	for _, test := range tests {
+		result := runCommand(t, dir, "", "--stats", test.filename)
+		stderr := compileTime.ReplaceAllString(result.stderr, "compiled in X\n")
+		if result.status != 0 || stderr != test.stderr {
+			t.Errorf("%s: exit status %d, stderr %q, expected %q", test.filename, result.status, stderr, test.stderr)
+		}
+		if strings.Count(result.stdout, "polygon(") != test.polygons {
+			t.Errorf("%s: unexpected stdout %q", test.filename, result.stdout)
+		}
+	}
+
+	result := runCommand(t, dir, "", "--stats-json", "lines.js")
+	var stats struct {
+		File string `json:"file"`
+		compileStats
+		CompileMillis float64 `json:"compile_ms"`
+	}
+	if err := json.Unmarshal([]byte(result.stderr), &stats); err != nil {
+		t.Fatalf("%v: %s", err, result.stderr)
+	}
+	if strings.Count(result.stderr, "\n") != 1 || stats.File != "lines.js" || stats.Polygons != 2 ||
+		stats.Points != 14 || stats.Segments != 3 || stats.PathLength != 17 ||
+		stats.Bounds == nil || *stats.Bounds != [2][2]float64{{-0.5, -0.5}, {10.5, 17.5}} || stats.CompileMillis <= 0 {
+		t.Errorf("unexpected stats: %s", result.stderr)
+	}
+	result = runCommand(t, dir, "", "--stats-json", "empty.js")
+	if !strings.Contains(result.stderr, `"path_length":0,"bounds":null,`) {
+		t.Errorf("unexpected stats for an empty drawing: %s", result.stderr)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseSvgFile extracts the outlines of all <path>, <polyline>, <polygon>
+// and <line> elements in an SVG document.  Each subpath is returned as a
+// separate list of points, with curves and arcs approximated by
+// curveSegments straight segments each.  Transforms and units are ignored,
+// and the Y axis is flipped so that the drawing appears upright in
+// OpenSCAD's coordinate system.
+func parseSvgFile(r io.Reader, curveSegments int) ([][][2]float64, error) {
+	var paths [][][2]float64
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		attrs := map[string]string{}
+		for _, attr := range element.Attr {
+			attrs[attr.Name.Local] = attr.Value
+		}
+		var subpaths [][][2]float64
+		switch element.Name.Local {
+		case "path":
+			subpaths, err = parseSvgPath(attrs["d"], curveSegments)
+		case "polyline", "polygon":
+			var points [][2]float64
+			points, err = parseSvgPoints(attrs["points"])
+			if len(points) > 0 && element.Name.Local == "polygon" {
+				points = append(points, points[0])
+			}
+			subpaths = [][][2]float64{points}
+		case "line":
+			var points [][2]float64
+			points, err = parseSvgPoints(fmt.Sprintf("%s,%s %s,%s",
+				attrs["x1"], attrs["y1"], attrs["x2"], attrs["y2"]))
+			subpaths = [][][2]float64{points}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("<%s> element: %s", element.Name.Local, err)
+		}
+		for _, points := range subpaths {
+			if len(points) == 0 {
+				continue
+			}
+			for i := range points {
+				points[i][1] = -points[i][1]
+			}
+			paths = append(paths, points)
+		}
+	}
+	return paths, nil
+}
+
+// svgScanner splits SVG path data and point lists into commands and numbers.
+type svgScanner struct {
+	data string
+	pos  int
+}
+
+func (s *svgScanner) skipSeparators() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n,", s.data[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+func (s *svgScanner) done() bool {
+	s.skipSeparators()
+	return s.pos >= len(s.data)
+}
+
+// nextIsNumber reports whether the next token is a number (as opposed to a
+// path command letter).
+func (s *svgScanner) nextIsNumber() bool {
+	if s.done() {
+		return false
+	}
+	return strings.IndexByte("+-.0123456789", s.data[s.pos]) >= 0
+}
+
+func (s *svgScanner) command() byte {
+	s.skipSeparators()
+	c := s.data[s.pos]
+	s.pos++
+	return c
+}
+
+func (s *svgScanner) number() (float64, error) {
+	if !s.nextIsNumber() {
+		return 0, fmt.Errorf("expected number at offset %d", s.pos)
+	}
+	start := s.pos
+	if s.data[s.pos] == '+' || s.data[s.pos] == '-' {
+		s.pos++
+	}
+	seenDot := false
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		if c == '.' && !seenDot {
+			seenDot = true
+		} else if c < '0' || c > '9' {
+			break
+		}
+		s.pos++
+	}
+	if s.pos < len(s.data) && (s.data[s.pos] == 'e' || s.data[s.pos] == 'E') {
+		s.pos++
+		if s.pos < len(s.data) && (s.data[s.pos] == '+' || s.data[s.pos] == '-') {
+			s.pos++
+		}
+		for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+			s.pos++
+		}
+	}
+	return strconv.ParseFloat(s.data[start:s.pos], 64)
+}
+
+// flag reads an arc flag, which may be written without any separator before
+// the next number (e.g. "a1 1 0 01.5 1").
+func (s *svgScanner) flag() (bool, error) {
+	s.skipSeparators()
+	if s.pos < len(s.data) && (s.data[s.pos] == '0' || s.data[s.pos] == '1') {
+		s.pos++
+		return s.data[s.pos-1] == '1', nil
+	}
+	return false, fmt.Errorf("expected arc flag at offset %d", s.pos)
+}
+
+func (s *svgScanner) numbers(n int) ([]float64, error) {
+	values := make([]float64, n)
+	for i := range values {
+		value, err := s.number()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// parseSvgPoints parses the "points" attribute of <polyline> and <polygon>
+// elements.
+func parseSvgPoints(data string) ([][2]float64, error) {
+	s := &svgScanner{data: data}
+	var points [][2]float64
+	for !s.done() {
+		xy, err := s.numbers(2)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, [2]float64{xy[0], xy[1]})
+	}
+	return points, nil
+}
+
+// parseSvgPath parses the "d" attribute of a <path> element.
+func parseSvgPath(data string, curveSegments int) ([][][2]float64, error) {
+	s := &svgScanner{data: data}
+	var subpaths [][][2]float64
+	var points [][2]float64
+	var x, y, startX, startY float64
+	// Reflected control point of the previous curve, for S/s and T/t
+	var ctrlX, ctrlY float64
+	var command, prevCommand byte
+
+	endSubpath := func() {
+		if len(points) > 0 {
+			subpaths = append(subpaths, points)
+		}
+		points = nil
+	}
+	lineTo := func(nx, ny float64) {
+		if len(points) == 0 {
+			points = append(points, [2]float64{x, y})
+		}
+		x, y = nx, ny
+		points = append(points, [2]float64{x, y})
+	}
+	cubicTo := func(x1, y1, x2, y2, x3, y3 float64) {
+		x0, y0 := x, y
+		for i := 1; i <= curveSegments; i++ {
+			t := float64(i) / float64(curveSegments)
+			u := 1 - t
+			lineTo(
+				u*u*u*x0+3*u*u*t*x1+3*u*t*t*x2+t*t*t*x3,
+				u*u*u*y0+3*u*u*t*y1+3*u*t*t*y2+t*t*t*y3)
+		}
+		ctrlX, ctrlY = x2, y2
+	}
+	quadTo := func(x1, y1, x2, y2 float64) {
+		x0, y0 := x, y
+		for i := 1; i <= curveSegments; i++ {
+			t := float64(i) / float64(curveSegments)
+			u := 1 - t
+			lineTo(
+				u*u*x0+2*u*t*x1+t*t*x2,
+				u*u*y0+2*u*t*y1+t*t*y2)
+		}
+		ctrlX, ctrlY = x1, y1
+	}
+
+	for !s.done() {
+		if s.nextIsNumber() {
+			if command == 0 {
+				return nil, fmt.Errorf("path data must begin with a command")
+			}
+			if command == 'Z' || command == 'z' {
+				// Z takes no arguments, so repeating it would read nothing
+				return nil, fmt.Errorf("unexpected number after '%c' at offset %d", command, s.pos)
+			}
+			// Implicit repetition of the previous command
+			if command == 'M' {
+				command = 'L'
+			} else if command == 'm' {
+				command = 'l'
+			}
+		} else {
+			command = s.command()
+		}
+
+		relative := command >= 'a' && command <= 'z'
+		var dx, dy float64
+		if relative {
+			dx, dy = x, y
+		}
+
+		switch command {
+		case 'M', 'm':
+			xy, err := s.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			endSubpath()
+			x, y = xy[0]+dx, xy[1]+dy
+			startX, startY = x, y
+		case 'L', 'l':
+			xy, err := s.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			lineTo(xy[0]+dx, xy[1]+dy)
+		case 'H', 'h':
+			v, err := s.number()
+			if err != nil {
+				return nil, err
+			}
+			lineTo(v+dx, y)
+		case 'V', 'v':
+			v, err := s.number()
+			if err != nil {
+				return nil, err
+			}
+			lineTo(x, v+dy)
+		case 'C', 'c':
+			c, err := s.numbers(6)
+			if err != nil {
+				return nil, err
+			}
+			cubicTo(c[0]+dx, c[1]+dy, c[2]+dx, c[3]+dy, c[4]+dx, c[5]+dy)
+		case 'S', 's':
+			c, err := s.numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			x1, y1 := x, y
+			if strings.IndexByte("CcSs", prevCommand) >= 0 {
+				x1, y1 = 2*x-ctrlX, 2*y-ctrlY
+			}
+			cubicTo(x1, y1, c[0]+dx, c[1]+dy, c[2]+dx, c[3]+dy)
+		case 'Q', 'q':
+			c, err := s.numbers(4)
+			if err != nil {
+				return nil, err
+			}
+			quadTo(c[0]+dx, c[1]+dy, c[2]+dx, c[3]+dy)
+		case 'T', 't':
+			c, err := s.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			x1, y1 := x, y
+			if strings.IndexByte("QqTt", prevCommand) >= 0 {
+				x1, y1 = 2*x-ctrlX, 2*y-ctrlY
+			}
+			quadTo(x1, y1, c[0]+dx, c[1]+dy)
+		case 'A', 'a':
+			r, err := s.numbers(3)
+			if err != nil {
+				return nil, err
+			}
+			largeArc, err := s.flag()
+			if err != nil {
+				return nil, err
+			}
+			sweep, err := s.flag()
+			if err != nil {
+				return nil, err
+			}
+			xy, err := s.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			for _, point := range svgArcPoints(x, y, r[0], r[1], r[2],
+				largeArc, sweep, xy[0]+dx, xy[1]+dy, curveSegments) {
+				lineTo(point[0], point[1])
+			}
+		case 'Z', 'z':
+			if len(points) > 0 {
+				lineTo(startX, startY)
+			}
+			endSubpath()
+			x, y = startX, startY
+		default:
+			return nil, fmt.Errorf("unknown path command '%c'", command)
+		}
+		prevCommand = command
+	}
+	endSubpath()
+
+	return subpaths, nil
+}
+
+// svgArcPoints approximates an SVG elliptical arc with straight segments,
+// returning the points after the starting point.  See
+// https://www.w3.org/TR/SVG/implnote.html#ArcConversionEndpointToCenter
+func svgArcPoints(x1, y1, rx, ry, rotation float64, largeArc, sweep bool,
+	x2, y2 float64, curveSegments int) [][2]float64 {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || (x1 == x2 && y1 == y2) {
+		return [][2]float64{{x2, y2}}
+	}
+	cosPhi, sinPhi := degCos(rotation), degSin(rotation)
+	// Step 1: compute (x1', y1')
+	mx, my := (x1-x2)/2, (y1-y2)/2
+	x1p := cosPhi*mx + sinPhi*my
+	y1p := -sinPhi*mx + cosPhi*my
+	// Scale up radii that are too small to reach the end point
+	if lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+	// Step 2: compute (cx', cy')
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(0, num/den))
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cxp := coef * rx * y1p / ry
+	cyp := -coef * ry * x1p / rx
+	// Step 3: compute (cx, cy)
+	cx := cosPhi*cxp - sinPhi*cyp + (x1+x2)/2
+	cy := sinPhi*cxp + cosPhi*cyp + (y1+y2)/2
+	// Step 4: compute the start angle and sweep angle
+	theta1 := math.Atan2((y1p-cyp)/ry, (x1p-cxp)/rx)
+	theta2 := math.Atan2((-y1p-cyp)/ry, (-x1p-cxp)/rx)
+	delta := theta2 - theta1
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+
+	points := make([][2]float64, 0, curveSegments)
+	for i := 1; i < curveSegments; i++ {
+		theta := theta1 + delta*float64(i)/float64(curveSegments)
+		ex, ey := rx*math.Cos(theta), ry*math.Sin(theta)
+		points = append(points, [2]float64{
+			cosPhi*ex - sinPhi*ey + cx,
+			sinPhi*ex + cosPhi*ey + cy,
+		})
+	}
+	// Use the exact end point to avoid accumulating rounding errors
+	return append(points, [2]float64{x2, y2})
+}
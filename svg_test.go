@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSvgPathErrors(t *testing.T) {
+	tests := []struct {
+		data, err string
+	}{
+		{"M0 0 L1 1 Z 5 5", "unexpected number after 'Z' at offset 12"},
+		{"M0 0 l1 1 z5 5", "unexpected number after 'z' at offset 11"},
+		{"M0 0 L1", "expected number at offset 7"},
+		{"M0 0 C1 1 2 2", "expected number at offset 13"},
+		{"5 5", "path data must begin with a command"},
+	}
+	for _, test := range tests {
+		_, err := parseSvgPath(test.data, 4)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("parseSvgPath(%q) returned error %v, expected %q", test.data, err, test.err)
+		}
+	}
+}
+
+func TestParseSvgPathClosed(t *testing.T) {
+	subpaths, err := parseSvgPath("M0 0 L1 0 L1 1 Z M5 5 L6 5", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subpaths) != 2 || len(subpaths[0]) != 4 || subpaths[0][3] != [2]float64{0, 0} {
+		t.Errorf("unexpected subpaths: %v", subpaths)
+	}
+}
@@ -0,0 +1,8 @@
+end_cap_sides(4);
+pendown();
+forward(3);
+anchor('hinge');
+left(90);
+forward(2);
+penup();
+anchor('latch', 1, 0.5, 180);
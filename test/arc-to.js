@@ -0,0 +1,15 @@
+end_cap_sides(12);
+pensize(1);
+pendown();
+forward(5);
+arc_to(5, 6, 3);
+echo('// ' + heading());
+forward(5);
+arc_to(0, 0, 3, 'left');
+echo('// ' + heading());
+penup();
+setpos(10, 0);
+pendown();
+arc_to(14, 0, 4, 'right');
+echo('// ' + heading().toFixed(6));
+penup();
@@ -0,0 +1,12 @@
+backend('bosl2');
+end_cap_sides(8);
+pendown();
+forward(4);
+left(90);
+pensize(2);
+forward(2);
+penup();
+
+jump(0, 5);
+pendown();
+penup();
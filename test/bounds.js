@@ -0,0 +1,17 @@
+emit_bounds('module');
+pensize(2);
+end_cap_sides(4);
+echo('// ' + JSON.stringify(bounds()));
+pendown();
+forward(10);
+left(90);
+forward(5);
+penup();
+echo('// ' + JSON.stringify(bounds()));
+symmetry('x');
+units('cm');
+setpos(1, 1);
+pendown();
+forward(1);
+penup();
+echo('// ' + JSON.stringify(bounds()));
@@ -0,0 +1,14 @@
+calligraphy(2, 45);
+pendown();
+forward(5);
+left(90);
+forward(5);
+left(45);
+forward(3);
+penup();
+calligraphy(2, 45, 0.5);
+setpos(10, 0);
+left(135);
+pendown();
+forward(3);
+penup();
@@ -0,0 +1,12 @@
+end_cap_sides(4);
+pensize(2);
+chamfer(1);
+pendown();
+forward(10);
+left(90);
+forward(10);
+right(90);
+forward(4);
+penup();
+fillet(2);
+echo('// ' + chamfer() + ' ' + fillet());
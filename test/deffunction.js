@@ -0,0 +1,23 @@
+param('teeth', 12);
+var toothHeight = deffunction('tooth_height', function(module) {
+	return 2.25 * module;
+});
+deffunction('pitch_radius', function(module, n) {
+	var diameter = module * n;
+	if (n < 3) {
+		return 0;
+	}
+	return diameter / 2 + Math.sin(Math.PI / n);
+});
+deffunction('clamp', function(x, lo, hi) {
+	return x < lo ? lo : (x > hi ? hi : x);
+});
+deffunction('outer_radius', function(module) {
+	return pitch_radius(module, teeth) + tooth_height(module);
+});
+
+end_cap_sides(4);
+pensize(toothHeight(0.5));
+pendown();
+forward(3);
+penup();
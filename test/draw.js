@@ -0,0 +1,12 @@
+end_cap_sides(4);
+pensize(1);
+draw([[0, 0], [10, 0], [10, 10]]);
+echo('// ' + heading());
+var path = record(function() {
+    pendown();
+    forward(3);
+    penup();
+});
+pendown();
+draw(path.translate(20, 0));
+penup();
@@ -0,0 +1,12 @@
+end_cap_sides(8);
+pensize(2);
+fillet(1);
+pendown();
+forward(10);
+left(90);
+forward(10);
+right(45);
+forward(2);
+penup();
+fillet(false);
+echo('// ' + fillet());
@@ -0,0 +1,496 @@
+// TypeScript definitions for the go-scad library.
+// Generated by `go-scad --emit-dts`; do not edit.
+
+/**
+ * Lines recorded by record().
+ */
+interface Path {
+	replay(): void;
+	translate(dx: number, dy: number): Path;
+	rotate(angle: number): Path;
+	scale(s: number): Path;
+	scale(sx: number, sy: number): Path;
+	reverse(): Path;
+	strokes(): number[][][];
+}
+
+/**
+ * Puts the pen down, so that moving the turtle draws a line.
+ */
+declare function pendown(): void;
+declare const pd: typeof pendown;
+declare const down: typeof pendown;
+
+/**
+ * Lifts the pen up and writes the line drawn since it was put down.
+ */
+declare function penup(): void;
+declare const pu: typeof penup;
+declare const up: typeof penup;
+
+/**
+ * Gets or sets the thickness of the lines drawn by the pen.
+ */
+declare function pensize(): number;
+declare function pensize(size: number): void;
+declare const width: typeof pensize;
+
+/**
+ * Gets or sets the height that lines are drawn with in 3D, for ramps
+ * and terraces.  The height is recorded for each point.  Each segment
+ * of a line with a height is extruded separately, and slopes between
+ * the heights at its ends.  0 draws flat lines again.
+ */
+declare function penheight(): number;
+declare function penheight(height: number): void;
+
+/**
+ * Gets or sets the number of sides used to draw a full circle at the ends
+ * of lines.  Must be even.
+ */
+declare function end_cap_sides(): number;
+declare function end_cap_sides(sides: number): void;
+
+/**
+ * Moves the turtle forward in the direction it is facing.
+ */
+declare function forward(distance: number): void;
+
+/**
+ * Moves the turtle forward in the direction it is facing without
+ * drawing.  If the pen is down, the line being drawn ends here and a
+ * new one starts at the new position.
+ */
+declare function move(distance: number): void;
+
+/**
+ * Moves the turtle by the given offsets without drawing.  If the pen is
+ * down, the line being drawn ends here and a new one starts at the new
+ * position.
+ */
+declare function jump(dx: number, dy: number): void;
+
+/**
+ * Turns the turtle clockwise by the given angle in degrees.
+ */
+declare function right(angle: number): void;
+declare const rt: typeof right;
+
+/**
+ * Turns the turtle counterclockwise by the given angle in degrees.
+ */
+declare function left(angle: number): void;
+declare const lt: typeof left;
+
+/**
+ * Moves the turtle to the given position without changing its heading.
+ */
+declare function setpos(x: number, y: number): void;
+declare const setposition: typeof setpos;
+
+/**
+ * Moves the turtle to the point at distance r from the origin, at an
+ * angle of theta degrees counterclockwise from the X axis.
+ */
+declare function polar(r: number, theta: number): void;
+
+/**
+ * Traces a parametric curve by calling fn with steps + 1 evenly spaced
+ * values of t from tStart to tEnd.  fn returns the [x, y] point on the
+ * curve for each value.  The turtle moves to the first point without
+ * drawing, then through the rest of the points, and finally faces in
+ * the direction of the end of the curve.
+ */
+declare function trace(fn: (t: number) => number[], tStart: number, tEnd: number, steps: number): void;
+
+/**
+ * Moves the turtle to (x, y) along the shorter circular arc of radius r,
+ * curving to the left (counterclockwise, the default) or to the right.
+ * The arc is drawn with one segment for every 360 / end_cap_sides()
+ * degrees, and the turtle ends up facing along the end of the arc.
+ */
+declare function arc_to(x: number, y: number, r: number, direction?: "left" | "right"): void;
+
+/**
+ * Returns the direction the turtle is facing in degrees.
+ */
+declare function heading(): number;
+
+/**
+ * Returns the total length of the lines drawn so far.  Lines drawn
+ * inside record() are counted each time they are replayed.
+ */
+declare function pathlength(): number;
+
+/**
+ * Returns the number of times the turtle has moved with the pen
+ * down so far.  Lines rounded by smooth() or fillet() count as the
+ * moves they were drawn with, not the segments of their curves.
+ */
+declare function segments(): number;
+
+/**
+ * Saves the turtle's position, heading, pen state and pen settings, so
+ * that they can be restored with pop().
+ */
+declare function push(): void;
+
+/**
+ * Restores the turtle state saved by the most recent call to push().
+ * The turtle does not draw while moving back to the saved position.
+ */
+declare function pop(): void;
+
+/**
+ * Calls fn count times, passing the number of the repetition (from 0).
+ */
+declare function repeat(count: number, fn: (i: number) => void): void;
+
+/**
+ * Gets or sets the axis that lines are mirrored across.  The axis may
+ * be "x", "y", or the angle in degrees of a line through the origin.
+ * false turns off mirroring.  Takes effect the next time the pen is put
+ * down.
+ */
+declare function symmetry(): number | false;
+declare function symmetry(axis: "x" | "y" | number | false): void;
+
+/**
+ * Calls fn and records the lines it draws instead of writing them to the
+ * output.  Afterwards the turtle is returned to its previous state.  The
+ * returned path has the methods replay(), translate(dx, dy),
+ * rotate(angle), scale(s) or scale(sx, sy), reverse() and strokes().
+ * Transformations return a new path and are relative to the origin.
+ */
+declare function record(fn: () => void): Path;
+
+/**
+ * Expands an L-system by applying the given rules to the axiom the
+ * given number of times, then draws the result.  actions gives the
+ * distance to move for a letter (drawing if the letter is uppercase,
+ * otherwise moving without drawing) or the angle to turn left for "+"
+ * and right for "-" (defaulting to the angle for "+"), or a function
+ * to call.  "[" and "]" push and pop the turtle state, "|" turns
+ * around, and other symbols are ignored.
+ */
+declare function lsystem(axiom: string, rules: {[symbol: string]: string}, iterations: number, actions: {[symbol: string]: number | (() => void)}): void;
+
+/**
+ * Gets or sets the thickness of the walls that lines are drawn with.
+ * Each line becomes an outline of this thickness instead of being
+ * solid.  0 or false draws solid lines again.  Takes effect the next
+ * time the pen is put down.
+ */
+declare function hollow(): number;
+declare function hollow(wall: number | false): void;
+
+/**
+ * Gets or sets whether lines are drawn in 3D as tubes with a round
+ * cross-section as thick as the pen, instead of as flat outlines.  Each
+ * segment is the convex hull of spheres at its ends, with
+ * end_cap_sides() sides.  Hollow tubes are closed at the ends.  Takes
+ * effect the next time the pen is put down.
+ */
+declare function tube(): boolean;
+declare function tube(enabled: boolean): void;
+
+/**
+ * Gets or sets the shape of the pen: "round" (the default), "square"
+ * (with sides as long as the pen size, and not rotated as the turtle
+ * turns), or a convex polygon given as a list of [x, y] offsets from
+ * the turtle's position.  Lines are drawn by sweeping the pen along
+ * them.  Takes effect the next time the pen is put down.
+ */
+declare function penshape(): "round" | "square" | Array<[number, number]>;
+declare function penshape(shape: "round" | "square" | Array<[number, number]>): void;
+
+/**
+ * Sets the shape of the pen to a flat nib of the given width, held at
+ * a fixed angle (in degrees counterclockwise from the X axis), so that
+ * lines are thickest when drawn across the nib and thinnest when drawn
+ * along it.  thickness (default 0) is the width of these thinnest
+ * lines.  Use penshape("round") to go back to a round pen.  Takes
+ * effect the next time the pen is put down.
+ */
+declare function calligraphy(width: number, angle: number, thickness?: number): void;
+
+/**
+ * Gets or sets a function which gives half the thickness of each line
+ * at a distance t along it, replacing the pen size.  If the normalized
+ * option is true, t goes from 0 at the start of the line to 1 at the
+ * end.  Each line is split into the given number of samples (default
+ * 32) so that its thickness changes smoothly.  false draws lines with
+ * the pen size again.  Takes effect the next time the pen is put down.
+ */
+declare function penwidth(): ((t: number) => number) | false;
+declare function penwidth(fn: ((t: number) => number) | false, options?: {normalized?: boolean, samples?: number}): void;
+
+/**
+ * Gets or sets the number of samples between each pair of points when
+ * lines are drawn as smooth curves (Catmull-Rom splines) through the
+ * points the turtle moves to, instead of straight segments.  true uses
+ * 8 samples, and 0 or false draws straight segments again.  Takes
+ * effect the next time the pen is put down.
+ */
+declare function smooth(): number;
+declare function smooth(samples: number | boolean): void;
+
+/**
+ * Gets or sets the radius that corners are rounded to.  The inside
+ * edge of each corner becomes an arc of this radius, and the outside
+ * edge an arc around the same center.  0 or false draws sharp corners
+ * again.  Replaces chamfer().  Takes effect the next time the pen is
+ * put down.
+ */
+declare function fillet(): number;
+declare function fillet(radius: number | false): void;
+
+/**
+ * Gets or sets how far from each corner the corner is cut off with a
+ * straight bevel, measured along the middle of the line.  0 or false
+ * draws sharp corners again.  Replaces fillet().  Takes effect the
+ * next time the pen is put down.
+ */
+declare function chamfer(): number;
+declare function chamfer(setback: number | false): void;
+
+/**
+ * Calls fn and collects the OpenSCAD code it generates into a module
+ * named layer_<name>.  The module is used where layer() is first
+ * called with this name, if the variable show_<name> is true, which
+ * allows the layer to be turned off in OpenSCAD's Customizer.  Later
+ * calls add to the same module.
+ */
+declare function layer(name: string, fn: () => void): void;
+
+/**
+ * Declares a variable at the top of the output which can be changed in
+ * OpenSCAD's Customizer, and returns its value.  options may give a
+ * description, a slider range (max, and optionally min and step), or a
+ * dropdown list of choices, each a value or a [value, label] pair.
+ */
+declare function param(name: string, value: number | string | boolean, options?: {min?: number, max?: number, step?: number, choices?: Array<number | string | [number | string, string]>, description?: string}): number | string | boolean;
+
+/**
+ * Starts a new group (shown as a tab or section in OpenSCAD's
+ * Customizer) for the variables declared by later calls to param() and
+ * layer().
+ */
+declare function param_group(name: string): void;
+
+/**
+ * Names a point where other parts can be attached to the drawing, by
+ * hand-written OpenSCAD code which includes the output.  It is written as
+ * a variable anchor_<name> = [x, y, heading].  Uses the turtle's position
+ * and heading by default.
+ */
+declare function anchor(name: string): void;
+declare function anchor(name: string, x: number, y: number, heading?: number): void;
+
+/**
+ * Gets or sets how lines are written to the output: "polygon" (the
+ * default) writes polygons of their outlines, and "bosl2" writes their
+ * paths as calls to the BOSL2 library's stroke() module, which has round
+ * joins.  Dots and lines drawn with other pen shapes are always written
+ * as polygons.
+ */
+declare function backend(): "polygon" | "bosl2";
+declare function backend(name: "polygon" | "bosl2"): void;
+
+/**
+ * Gets or sets whether the whole drawing is wrapped in a single union()
+ * block, so that it is one 2D region for operations such as
+ * linear_extrude() and difference().  Off by default, unless the
+ * --union-all flag is given.
+ */
+declare function autounion(): boolean;
+declare function autounion(enabled: boolean): void;
+
+/**
+ * Returns the corners of the smallest rectangle containing all of the
+ * lines drawn so far (not counting a line that the pen is still down
+ * for, or code written by echo()), or null if nothing has been drawn.
+ */
+declare function bounds(): {min: [number, number], max: [number, number]} | null;
+
+/**
+ * Writes the final bounding box of everything drawn at the end of the
+ * output, either as a comment or as a module called bounding_box()
+ * which draws it as a square.
+ */
+declare function emit_bounds(format: "comment" | "module" | false): void;
+
+/**
+ * Wraps the OpenSCAD code generated by fn in a block, e.g.
+ * wrap('linear_extrude(height = 3)', fn).  scad() does the same with
+ * the module's parameters given as an object.
+ */
+declare function wrap(wrapper: string, fn: () => void): void;
+
+/**
+ * Calls the OpenSCAD module with the given name and parameters, e.g.
+ * scad('linear_extrude', {height: 3, twist: 90}, fn), wrapping the
+ * OpenSCAD code generated by fn.  Without fn, the module is called on
+ * its own, e.g. scad('circle', {r: 2}).  Parameter values may be
+ * numbers, strings, booleans, arrays of these, or null for undef, and
+ * are written the same way as the rest of the output.  Lengths are not
+ * converted from the current units.
+ */
+declare function scad(name: string, params?: {[name: string]: any}, fn?: () => void): void;
+
+/**
+ * Transforms the OpenSCAD code generated by fn with a multmatrix()
+ * block.  m is a 4x4 matrix, or its first 3 rows.  The translation in
+ * the last column is in the current units.
+ */
+declare function matrix(m: number[][], fn: () => void): void;
+
+/**
+ * Shears the OpenSCAD code generated by fn, so that each point moves
+ * sx times its Y coordinate along the X axis, and sy times its X
+ * coordinate along the Y axis.
+ */
+declare function shear(sx: number, sy: number, fn: () => void): void;
+
+/**
+ * Wraps the OpenSCAD code generated by fn in a block with the #
+ * modifier, so that it is highlighted in red in OpenSCAD's preview.
+ */
+declare function debug(fn: () => void): void;
+
+/**
+ * Wraps the OpenSCAD code generated by fn in a block with the %
+ * modifier, so that it is shown transparent in OpenSCAD's preview, and
+ * left out of the rendered model.
+ */
+declare function background(fn: () => void): void;
+
+/**
+ * Wraps the OpenSCAD code generated by fn in a block with the !
+ * modifier, so that it is drawn by itself, ignoring the rest of the
+ * model.
+ */
+declare function root(fn: () => void): void;
+
+/**
+ * Wraps the OpenSCAD code generated by fn in a block with the *
+ * modifier, so that it is left out of the model.
+ */
+declare function disable(fn: () => void): void;
+
+/**
+ * Writes OpenSCAD code directly to the output.
+ */
+declare function echo(code: string): void;
+
+/**
+ * Writes an OpenSCAD import() statement for an STL, OFF, DXF or SVG
+ * file, moved to the turtle's position and rotated to its heading.
+ * The file is loaded by OpenSCAD relative to the output file.
+ */
+declare function scad_import(filename: string, options?: {convexity?: number}): void;
+
+/**
+ * Writes an OpenSCAD surface() statement for a heightmap (a PNG image
+ * or a DAT file), moved to the turtle's position and rotated to its
+ * heading.  The file is loaded by OpenSCAD relative to the output file.
+ */
+declare function scad_surface(filename: string, options?: {center?: boolean, invert?: boolean, convexity?: number}): void;
+
+/**
+ * Gets or sets the units used for lengths and positions given to and
+ * returned by other functions.  The output is always in millimeters.
+ */
+declare function units(): string;
+declare function units(units: "mm" | "cm" | "in"): void;
+
+/**
+ * Converts a length in inches into the current units.
+ */
+declare function inch(inches: number): number;
+
+/**
+ * Converts a length in millimeters into the current units.
+ */
+declare function mm(millimeters: number): number;
+
+/**
+ * Gets or sets the grid size that the coordinates of points in the
+ * output are rounded to, for example 0.01.  0 turns off rounding.
+ */
+declare function snap(): number;
+declare function snap(grid: number): void;
+
+/**
+ * Returns a random number between 0 (or min) and 1 (or max).  The
+ * numbers are the same every time the script runs, unless the seed is
+ * changed with seed() or --seed.  Math.random() returns the same numbers.
+ */
+declare function random(): number;
+declare function random(min: number, max: number): number;
+
+/**
+ * Sets the seed used to generate the numbers returned by random().
+ */
+declare function seed(seed: number): void;
+
+/**
+ * Loads the outlines in an SVG file as lists of [x, y] points, one per
+ * subpath.  Curves are divided into curveSegments segments (default 16).
+ */
+declare function load_svg(filename: string, curveSegments?: number): number[][][];
+
+/**
+ * Loads a list of [x, y] points from the first two columns of a CSV file.
+ */
+declare function load_points(filename: string): number[][];
+
+/**
+ * Loads and parses a JSON file.
+ */
+declare function load_json(filename: string): any;
+
+/**
+ * Moves the turtle to the first of the given [x, y] points without
+ * drawing, then through the rest of the points.
+ */
+declare function follow(points: number[][]): void;
+
+/**
+ * Draws a line through the given [x, y] points, or the lines in a path
+ * returned by record(), whether or not the pen is down.  Afterwards the
+ * turtle is at the end of the line, facing along its last segment, and
+ * the pen is up or down as it was before.
+ */
+declare function draw(points: number[][] | Path): void;
+
+/**
+ * Fills the space between two paths returned by record(), which must
+ * have the same number of points, joining each point of pathA to the
+ * point in the same place in pathB.  Without a height, a polygon is
+ * written between the paths.  With a height, the paths are closed
+ * outlines, and a solid is written with pathA at the bottom and pathB
+ * this far above it, such as a duct from a rectangle to a circle.
+ */
+declare function loft(pathA: Path, pathB: Path): void;
+declare function loft(pathA: Path, pathB: Path, height: number): void;
+
+/**
+ * Writes an OpenSCAD function with the given name which does the same
+ * as fn, so that values computed from parameters stay editable in the
+ * output, and returns fn.  fn may only declare variables with var,
+ * return values (using if statements to choose between them), and use
+ * arithmetic, comparisons, arrays, Math functions, variables declared
+ * with param(), and other functions defined with deffunction().
+ * Values are not converted between units.
+ */
+declare function deffunction<T extends (...args: any[]) => any>(name: string, fn: T): T;
+
+/**
+ * Adds a command with the given name which calls fn, usually from a
+ * plugin loaded with --plugin.  A command can't replace a built-in
+ * function or another command.  options may give the command's
+ * TypeScript signature, e.g. "(teeth: number): void", and its
+ * documentation for --emit-dts.
+ */
+declare function register(name: string, fn: (...args: any[]) => any, options?: {signature?: string, doc?: string}): void;
@@ -0,0 +1,9 @@
+end_cap_sides(4);
+pensize(2);
+hollow(0.25);
+pendown();
+forward(3);
+penup();
+hollow(false);
+pendown();
+penup();
@@ -0,0 +1,7 @@
+scad_import('bracket.stl');
+forward(10);
+left(90);
+scad_import('bracket.stl', { convexity: 4 });
+units('cm');
+setpos(2, 3);
+scad_surface('heightmap.png', { center: true, convexity: 5 });
@@ -0,0 +1,12 @@
+end_cap_sides(4);
+pensize(1);
+pendown();
+forward(5);
+move(2);
+forward(5);
+jump(0, 3);
+left(90);
+forward(2);
+penup();
+jump(1, 1);
+echo('// ' + JSON.stringify(bounds()));
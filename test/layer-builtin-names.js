@@ -0,0 +1,8 @@
+// Layers named after OpenSCAD's modules don't replace them
+end_cap_sides(4);
+layer('polygon', function() {
+	pendown();
+	forward(1);
+	penup();
+});
+layer('union', function() {});
@@ -0,0 +1,15 @@
+pensize(1);
+pendown();
+forward(10);
+layer('holes', function() {
+    right(90);
+    forward(5);
+});
+forward(5);
+penup();
+layer('holes', function() {
+    setpos(0, 0);
+    pendown();
+    forward(2);
+    penup();
+});
@@ -0,0 +1,12 @@
+var profile = load_points('load-data.csv');
+var settings = load_json('load-data.json');
+
+end_cap_sides(6);
+pensize(settings.width);
+pendown();
+follow(profile);
+for (var i = 0; i < settings.turns.length; i++) {
+	left(settings.turns[i]);
+	forward(2);
+}
+penup();
@@ -0,0 +1,9 @@
+var paths = load_svg('load-svg.svg', 4);
+
+end_cap_sides(6);
+pensize(0.5);
+pendown();
+for (var i = 0; i < paths.length; i++) {
+	follow(paths[i]);
+}
+penup();
@@ -0,0 +1,13 @@
+var lower = record(function() {
+	draw([[0, 0], [2, 1], [4, 0]]);
+});
+var upper = lower.translate(0, 3);
+loft(lower, upper);
+
+var square = record(function() {
+	draw([[-1, -1], [-1, 1], [1, 1], [1, -1], [-1, -1]]);
+});
+var diamond = record(function() {
+	draw([[-1, 0], [0, 1], [1, 0], [0, -1]]);
+});
+loft(square.translate(10, 0), diamond.translate(10, 0), 5);
@@ -0,0 +1,13 @@
+// Koch curve, and a simple branching plant
+end_cap_sides(4);
+pensize(0.2);
+pendown();
+lsystem('F', {F: 'F+F--F+F'}, 2, {F: 1, '+': 60});
+penup();
+
+setpos(0, 5);
+left(-heading());
+left(90);
+pendown();
+lsystem('X', {X: 'F[+X][-X]', F: 'FF'}, 2, {F: 0.5, '+': 30, '-': 20});
+penup();
@@ -0,0 +1,13 @@
+end_cap_sides(4);
+pensize(1);
+shear(0.5, 0, function() {
+    pendown();
+    forward(5);
+    penup();
+});
+units('cm');
+matrix([[1, 0, 0, 1], [0, 1, 0, 2], [0, 0, 1, 0]], function() {
+    pendown();
+    forward(1);
+    penup();
+});
@@ -0,0 +1,12 @@
+end_cap_sides(4);
+pensize(1);
+function stroke() {
+    pendown();
+    forward(2);
+    penup();
+    right(90);
+}
+debug(stroke);
+background(stroke);
+disable(stroke);
+root(stroke);
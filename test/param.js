@@ -0,0 +1,13 @@
+param_group('Dimensions');
+var length = param('length', 20, { min: 5, max: 50, step: 5, description: 'Length of the bar' });
+var width = param('width', 2, { max: 4 });
+param_group('Style');
+param('style', 'round', { choices: [['round', 'Round'], ['square', 'Square']] });
+param('sides', 8, { choices: [4, 8, 16] });
+param('label', "go-scad");
+layer('bar', function() {
+    pensize(width);
+    pendown();
+    forward(length);
+    penup();
+});
@@ -0,0 +1,26 @@
+end_cap_sides(4);
+pensize(0.5);
+pendown();
+forward(2);
+penup();
+echo('// ' + pathlength() + ' ' + segments());
+
+// Recording doesn't draw anything, so it isn't counted
+var square = record(function() {
+	pendown();
+	forward(1);
+	left(90);
+	forward(1);
+	left(90);
+	forward(1);
+	penup();
+});
+echo('// ' + pathlength() + ' ' + segments());
+
+// Each replay is counted, with the pen up or down
+square.translate(5, 0).replay();
+echo('// ' + pathlength() + ' ' + segments());
+pendown();
+square.scale(2).replay();
+penup();
+echo('// ' + pathlength() + ' ' + segments());
@@ -0,0 +1,25 @@
+end_cap_sides(4);
+pensize(0.5);
+pendown();
+forward(6);
+left(90);
+forward(4);
+penup();
+echo('// ' + pathlength() + ' ' + segments());
+
+// Put a dot every 5 units along a line
+var start = pathlength();
+setpos(0, 10);
+right(90);
+pendown();
+forward(10);
+penup();
+var length = pathlength() - start;
+pensize(2);
+for (var d = 0; d <= length; d += 5) {
+    setpos(d, 10);
+    pendown();
+    penup();
+}
+units('cm');
+echo('// ' + pathlength() + ' ' + segments());
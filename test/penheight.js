@@ -0,0 +1,12 @@
+end_cap_sides(4);
+penheight(2);
+pendown();
+forward(2);
+penheight(4);
+forward(2);
+penup();
+
+penheight(1);
+jump(0, 5);
+pendown();
+penup();
@@ -0,0 +1,14 @@
+pensize(2);
+penshape('square');
+pendown();
+forward(10);
+left(90);
+forward(5);
+penup();
+penshape([[0, 1], [1, -1], [-1, -1]]);
+setpos(20, 0);
+pendown();
+penup();
+echo('// ' + JSON.stringify(penshape()));
+penshape('round');
+echo('// ' + penshape());
@@ -0,0 +1,19 @@
+end_cap_sides(4);
+penwidth(function(t) {
+    return Math.sin(t * Math.PI) * 2;
+}, { normalized: true, samples: 8 });
+pendown();
+forward(10);
+penup();
+
+penwidth(function(d) {
+    return 1 / (1 + d);
+}, { samples: 4 });
+setpos(0, 5);
+pendown();
+forward(4);
+left(90);
+forward(4);
+penup();
+penwidth(false);
+echo('// ' + penwidth());
@@ -0,0 +1,17 @@
+end_cap_sides(4);
+pensize(0.2);
+pendown();
+polar(2, 0);
+polar(2, 90);
+penup();
+
+// Epicycloid
+pendown();
+trace(function(t) {
+	return [
+		4 * Math.cos(t) - Math.cos(4 * t),
+		4 * Math.sin(t) - Math.sin(4 * t)
+	];
+}, 0, 2 * Math.PI, 12);
+penup();
+echo('// ' + heading().toFixed(6));
@@ -0,0 +1,12 @@
+end_cap_sides(4);
+pendown();
+forward(2);
+push();
+left(45);
+pensize(0.5);
+forward(1);
+pop();
+right(45);
+forward(1);
+penup();
+echo('// ' + pensize() + ' ' + heading());
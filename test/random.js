@@ -0,0 +1,9 @@
+seed(42);
+end_cap_sides(4);
+pendown();
+for (var i = 0; i < 4; i++) {
+	left(random(-90, 90));
+	forward(1 + Math.random());
+}
+penup();
+echo('// ' + random().toFixed(6));
@@ -0,0 +1,22 @@
+end_cap_sides(4);
+pensize(0.5);
+var tooth = record(function() {
+	pendown();
+	forward(1);
+	left(90);
+	forward(1);
+	penup();
+});
+echo('// ' + tooth.strokes().length + ' ' + heading());
+
+tooth.replay();
+tooth.translate(3, 0).replay();
+tooth.rotate(90).scale(2).reverse().replay();
+
+pendown();
+forward(1);
+record(function() {
+	forward(5);
+});
+forward(1);
+penup();
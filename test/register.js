@@ -0,0 +1,14 @@
+register('zigzag', function(teeth, size) {
+	for (var i = 0; i < teeth; i++) {
+		left(45);
+		forward(size);
+		right(90);
+		forward(size);
+		left(45);
+	}
+}, {signature: '(teeth: number, size: number): void'});
+
+end_cap_sides(4);
+pendown();
+zigzag(2, 3);
+penup();
@@ -0,0 +1,7 @@
+end_cap_sides(4);
+pendown();
+repeat(4, function(i) {
+	forward(i + 1);
+	left(90);
+});
+penup();
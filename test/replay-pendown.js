@@ -0,0 +1,21 @@
+end_cap_sides(4);
+pensize(0.5);
+var tooth = record(function() {
+	pendown();
+	forward(1);
+	penup();
+});
+
+// Replaying with the pen down leaves the pen down afterwards, without
+// drawing a dot where it was put down or where the replay finished
+setpos(0, 5);
+pendown();
+tooth.replay();
+penup();
+
+setpos(0, 10);
+pendown();
+forward(1);
+tooth.translate(0, 10).replay();
+forward(1);
+penup();
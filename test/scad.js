@@ -0,0 +1,9 @@
+end_cap_sides(4);
+scad('linear_extrude', {height: 3, twist: 90, center: true, scale: [1, 0.5]}, function() {
+	pendown();
+	forward(2 / 3);
+	penup();
+});
+scad('circle', {r: 1 / 3, $fn: 24});
+scad('text', {text: 'Say "hi"\n', font: null});
+scad('union');
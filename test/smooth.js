@@ -0,0 +1,10 @@
+end_cap_sides(4);
+pensize(0.5);
+smooth(4);
+pendown();
+setpos(5, 5);
+setpos(10, 0);
+setpos(15, 5);
+penup();
+smooth(false);
+echo('// ' + smooth());
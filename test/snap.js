@@ -0,0 +1,9 @@
+end_cap_sides(6);
+snap(0.05);
+pendown();
+left(30);
+forward(3);
+penup();
+snap(0);
+pendown();
+penup();
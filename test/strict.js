@@ -0,0 +1,17 @@
+pensize(1);
+pendown();
+forward(0);
+forward(5);
+setpos(5, 0);
+right(170);
+forward(5);
+penup();
+
+setpos(20, 0);
+pendown();
+forward(10);
+left(90);
+forward(0.5);
+left(90);
+forward(10);
+penup();
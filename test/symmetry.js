@@ -0,0 +1,14 @@
+end_cap_sides(4);
+symmetry('x');
+setpos(1, 1);
+pendown();
+forward(2);
+penup();
+symmetry(45);
+pendown();
+left(90);
+forward(1);
+penup();
+symmetry(false);
+pendown();
+penup();
@@ -0,0 +1,21 @@
+end_cap_sides(8);
+tube(true);
+pensize(2);
+pendown();
+forward(10);
+left(90);
+forward(5);
+penup();
+
+hollow(0.5);
+jump(0, 10);
+pendown();
+forward(4);
+penup();
+
+hollow(false);
+tube(false);
+jump(0, 20);
+pendown();
+forward(1);
+penup();
@@ -0,0 +1,9 @@
+end_cap_sides(4);
+autounion(true);
+pendown();
+forward(1);
+penup();
+right(90);
+pendown();
+forward(1);
+penup();
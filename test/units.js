@@ -0,0 +1,10 @@
+units('in');
+end_cap_sides(4);
+pensize(mm(2));
+pendown();
+forward(1);
+left(90);
+forward(inch(0.5));
+setpos(0, 0.5);
+penup();
+echo('// ' + units() + ' ' + pensize().toFixed(6));
@@ -0,0 +1,141 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type testArgs struct {
+	globalArgs
+	Dir     string `arg:"positional" help:"directory containing the scripts to test (default: the current directory)"`
+	Update  bool   `help:"overwrite the expected output files with the current output"`
+	NoColor bool   `arg:"--no-color" help:"don't color the differences between actual and expected output"`
+	JSON    bool   `arg:"--json" help:"print a JSON summary of the results instead of text"`
+}
+
+func (testArgs) Description() string {
+	return ("Compiles each .js file in a directory and compares the output" +
+		" with the .js.scad file alongside it, and the warnings from" +
+		" --strict with the .js.warnings file if there is one.  Exits with" +
+		" an error if any test fails.")
+}
+
+// testSummary is the machine-readable summary printed by `go-scad test
+// --json`.
+type testSummary struct {
+	Passed  int            `json:"passed"`
+	Failed  int            `json:"failed"`
+	Errors  int            `json:"errors"`
+	Updated int            `json:"updated"`
+	Tests   []goldenResult `json:"tests"`
+}
+
+func runTests(argv []string) {
+	args := testArgs{Dir: "."}
+	p := parseArgs("go-scad test", argv, &args)
+	opts, _ := args.load(p)
+
+	paths, err := filepath.Glob(filepath.Join(args.Dir, "*.js"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(paths) == 0 {
+		log.Fatalf("no .js files found in %s", args.Dir)
+	}
+
+	// Run each test in parallel
+	summary := testSummary{Tests: make([]goldenResult, len(paths))}
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			summary.Tests[i] = runGoldenTest(path, opts, args.Update)
+		}(i, path)
+	}
+	wg.Wait()
+
+	dmp := diffmatchpatch.New()
+	for _, result := range summary.Tests {
+		switch result.Status {
+		case "pass":
+			summary.Passed++
+		case "fail":
+			summary.Failed++
+		case "error":
+			summary.Errors++
+		case "updated":
+			summary.Updated++
+		}
+		if args.JSON {
+			continue
+		}
+
+		fmt.Printf("%-7s %s\n", result.Status, result.Name)
+		if result.Error != "" {
+			fmt.Println(result.Error)
+		}
+		for _, name := range sortedDiffNames(result) {
+			diffs := result.Diffs[name]
+			if args.NoColor {
+				fmt.Printf("output doesn't match %s:\n- actual + expected\n%s\n",
+					name, plainDiff(dmp, diffs))
+			} else {
+				fmt.Printf("output doesn't match %s:\n"+
+					"\x1b[31m- actual\x1b[0m \x1b[32m+ expected\x1b[0m\n%s\n",
+					name, dmp.DiffPrettyText(diffs))
+			}
+		}
+	}
+
+	if args.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(summary); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		fmt.Printf("\n%d passed, %d failed, %d errors, %d updated\n",
+			summary.Passed, summary.Failed, summary.Errors, summary.Updated)
+	}
+	if summary.Failed > 0 || summary.Errors > 0 {
+		os.Exit(1)
+	}
+}
+
+// plainDiff formats the lines that differ between the actual and expected
+// output, without colors.
+func plainDiff(dmp *diffmatchpatch.DiffMatchPatch, diffs []diffmatchpatch.Diff) string {
+	actual, expected, lines := dmp.DiffLinesToChars(
+		dmp.DiffText1(diffs), dmp.DiffText2(diffs))
+	lineDiffs := dmp.DiffCharsToLines(dmp.DiffMain(actual, expected, false), lines)
+
+	var out strings.Builder
+	for _, diff := range lineDiffs {
+		prefix := ""
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			prefix = "- "
+		case diffmatchpatch.DiffInsert:
+			prefix = "+ "
+		default:
+			continue
+		}
+		for _, line := range strings.SplitAfter(diff.Text, "\n") {
+			if line != "" {
+				out.WriteString(prefix + strings.TrimSuffix(line, "\n") + "\n")
+			}
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,85 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTestCommand(t *testing.T) {
+	line := func(length string) string {
+		return "polygon(points = [\n" +
+			"\t[0,-0.5], [-0.5,0], [0,0.5],\n" +
+			"\t[" + length + ",0.5], [" + length + ".5,0], [" + length + ",-0.5],\n" +
+			"]);\n"
+	}
+	warningsScript := "end_cap_sides(4); pendown(); forward(0); forward(1); penup();"
+	warningsOutput, err := jsToScad(warningsScript, compileOptions{})
+	if err != nil {
+		t.Fatal(formatError(err))
+	}
+	dir := writeFiles(t, map[string]string{
+		"a-pass.js":               "end_cap_sides(4); pendown(); forward(1); penup();",
+		"a-pass.js.scad":          line("1"),
+		"b-fail.js":               "end_cap_sides(4); pendown(); forward(2); penup();",
+		"b-fail.js.scad":          line("3"),
+		"c-error.js":              "nonexistent();",
+		"d-warnings.js":           warningsScript,
+		"d-warnings.js.scad":      warningsOutput,
+		"d-warnings.js.warnings":  "line 1: Zero-length forward move\n",
+		"e-no-expected-output.js": "forward(1);",
+	})
+
+	result := runCommand(t, dir, "", "test", "--no-color")
+	expected := "pass    a-pass.js\n" +
+		"fail    b-fail.js\n" +
+		"output doesn't match b-fail.js.scad:\n" +
+		"- actual + expected\n" +
+		"- \t[2,0.5], [2.5,0], [2,-0.5],\n" +
+		"+ \t[3,0.5], [3.5,0], [3,-0.5],\n" +
+		"\n" +
+		"error   c-error.js\n" +
+		"JavaScript error: ReferenceError: 'nonexistent' is not defined\n" +
+		"    at <anonymous>:1:1\n" +
+		"\n" +
+		"pass    d-warnings.js\n" +
+		"error   e-no-expected-output.js\n" +
+		"open e-no-expected-output.js.scad: no such file or directory\n" +
+		"\n" +
+		"2 passed, 1 failed, 2 errors, 0 updated\n"
+	if result.status != 1 || result.stdout != expected {
+		t.Errorf("exit status %d, stdout:\n%s\nexpected:\n%s", result.status, result.stdout, expected)
+	}
+
+	result = runCommand(t, dir, "", "test", "--json")
+	var summary testSummary
+	if err := json.Unmarshal([]byte(result.stdout), &summary); err != nil {
+		t.Fatalf("%v: %s", err, result.stdout)
+	}
+	if result.status != 1 || summary.Passed != 2 || summary.Failed != 1 || summary.Errors != 2 ||
+		len(summary.Tests) != 5 || summary.Tests[1].Name != "b-fail.js" || summary.Tests[1].Status != "fail" {
+		t.Errorf("exit status %d, summary %+v", result.status, summary)
+	}
+
+	// Updating fixes the failing test, but not the ones with errors
+	result = runCommand(t, dir, "", "test", "--update", "--no-color")
+	if result.status != 1 || !strings.HasSuffix(result.stdout, "\n0 passed, 0 failed, 1 errors, 4 updated\n") {
+		t.Errorf("--update: exit status %d, stdout:\n%s", result.status, result.stdout)
+	}
+	updated, err := ioutil.ReadFile(filepath.Join(dir, "b-fail.js.scad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(updated) != line("2") {
+		t.Errorf("b-fail.js.scad was updated to:\n%s", updated)
+	}
+	result = runCommand(t, dir, "", "test", "--no-color", dir)
+	if !strings.HasSuffix(result.stdout, "\n4 passed, 0 failed, 1 errors, 0 updated\n") {
+		t.Errorf("after --update: stdout:\n%s", result.stdout)
+	}
+}
@@ -0,0 +1,102 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// checkWithOpenscad runs the openscad binary on generated OpenSCAD code,
+// returning an error which lists any warnings or errors that it reports.
+// The code is written to a temporary file in dir, so that any files it
+// imports are found.
+func checkWithOpenscad(dir string, scad string) error {
+	problems, err := runOpenscad(dir, scad, "",
+		"--check-parameters=true",
+		"--check-parameters-ranges=true")
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errors.New("OpenSCAD found problems in the output:\n" +
+			strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// renderWithOpenscad runs the openscad binary to render generated OpenSCAD
+// code into a file, in a format chosen from the file's extension.  Warnings
+// from OpenSCAD are printed, and errors are returned.  As for
+// checkWithOpenscad, the code is written to a temporary file in dir.
+func renderWithOpenscad(dir string, scad string, output string) error {
+	problems, err := runOpenscad(dir, scad, output)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, problem := range problems {
+		if strings.HasPrefix(problem, "WARNING:") {
+			log.Print(problem)
+		} else {
+			errs = append(errs, problem)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New("OpenSCAD couldn't render the output:\n" +
+			strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// runOpenscad writes generated OpenSCAD code to a temporary file in dir and
+// runs the openscad binary on it with the given arguments, exporting to the
+// given file.  If output is empty, the code is only evaluated, without
+// rendering it.  It returns the warnings and errors that OpenSCAD reports,
+// including an error if it fails without reporting one.
+func runOpenscad(dir string, scad string, output string, args ...string) ([]string, error) {
+	openscad, err := exec.LookPath("openscad")
+	if err != nil {
+		return nil, errors.New("OpenSCAD is needed to validate or render the output: " + err.Error())
+	}
+
+	input, err := ioutil.TempFile(dir, ".go-scad-*.scad")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(input.Name())
+	_, err = input.WriteString(scad)
+	if closeErr := input.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Exporting to an echo file evaluates the code without rendering it
+	if output == "" {
+		output = strings.TrimSuffix(input.Name(), ".scad") + ".echo"
+		defer os.Remove(output)
+	}
+	cmd := exec.Command(openscad, append(args, "-o", output, input.Name())...)
+	messages, runErr := cmd.CombinedOutput()
+
+	var problems []string
+	for _, line := range strings.Split(string(messages), "\n") {
+		if strings.HasPrefix(line, "WARNING:") || strings.HasPrefix(line, "ERROR:") {
+			line = strings.Replace(line, input.Name(), "output", -1)
+			line = strings.Replace(line, filepath.Base(input.Name()), "output", -1)
+			problems = append(problems, line)
+		}
+	}
+	if runErr != nil && len(problems) == 0 {
+		problems = append(problems, "ERROR: openscad failed: "+runErr.Error())
+	}
+	return problems, nil
+}
@@ -0,0 +1,63 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"dot.js":  "pensize(0); pendown(); forward(5); penup();",
+		"line.js": "pendown(); forward(5); penup();",
+	})
+	result := runCommand(t, dir, "", "--validate", "dot.js")
+	if result.status != 1 || result.stdout != "" {
+		t.Errorf("dot.js: exit status %d, stdout %q", result.status, result.stdout)
+	}
+	if expected := "Invalid polygon for the line started on line 1: fewer than 3 distinct points"; !strings.Contains(result.stderr, expected) {
+		t.Errorf("dot.js: stderr %q doesn't contain %q", result.stderr, expected)
+	}
+	result = runCommand(t, dir, "", "--validate", "line.js")
+	if result.status != 0 || result.stderr != "" || !strings.HasPrefix(result.stdout, "polygon(") {
+		t.Errorf("line.js: exit status %d, stdout %q, stderr %q", result.status, result.stdout, result.stderr)
+	}
+}
+
+func TestValidateOpenscad(t *testing.T) {
+	// A stand-in for openscad, which complains if OPENSCAD_WARNING is set
+	dir := writeFiles(t, map[string]string{
+		"bin/openscad": "#!/bin/sh\n" +
+			"for input; do :; done\n" +
+			"if [ -n \"$OPENSCAD_WARNING\" ]; then\n" +
+			"\techo \"WARNING: $OPENSCAD_WARNING in file $input, line 1\" >&2\n" +
+			"fi\n",
+		"line.js": "pendown(); forward(5); penup();",
+	})
+	if err := os.Chmod(filepath.Join(dir, "bin", "openscad"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := "PATH=" + filepath.Join(dir, "bin") + string(os.PathListSeparator) + os.Getenv("PATH")
+
+	result := runCommandWithEnv(t, dir, []string{path}, "", "--validate-openscad", "line.js")
+	if result.status != 0 || result.stderr != "" || !strings.HasPrefix(result.stdout, "polygon(") {
+		t.Errorf("accepted: exit status %d, stdout %q, stderr %q", result.status, result.stdout, result.stderr)
+	}
+
+	result = runCommandWithEnv(t, dir, []string{path, "OPENSCAD_WARNING=Ignoring unknown variable 'x'"},
+		"", "--validate-openscad", "line.js")
+	expected := "OpenSCAD found problems in the output:\n" +
+		"WARNING: Ignoring unknown variable 'x' in file output, line 1\n"
+	if result.status != 1 || result.stdout != "" || result.stderr != expected {
+		t.Errorf("rejected: exit status %d, stdout %q, stderr %q", result.status, result.stdout, result.stderr)
+	}
+
+	result = runCommandWithEnv(t, dir, []string{"PATH=" + filepath.Join(dir, "missing")}, "", "--validate-openscad", "line.js")
+	if result.status != 1 || !strings.Contains(result.stderr, "OpenSCAD is needed to validate or render the output") {
+		t.Errorf("missing: exit status %d, stderr %q", result.status, result.stderr)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"syscall/js"
+)
+
+// When built with GOOS=js GOARCH=wasm, go-scad exposes a global JavaScript
+// function to the host page instead of reading files from the command line:
+//
+//	compile(source) -> {scad: "...", errors: ["..."]}
+//
+// Scripts compiled this way cannot load data files.
+func main() {
+	js.Global().Set("compile", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 1 || args[0].Type() != js.TypeString {
+			return map[string]interface{}{
+				"scad":   "",
+				"errors": []interface{}{"compile() expects a source string"},
+			}
+		}
+		output, err := jsToScad(args[0].String(), compileOptions{})
+		if err != nil {
+			return map[string]interface{}{
+				"scad":   "",
+				"errors": []interface{}{formatError(err)},
+			}
+		}
+		return map[string]interface{}{
+			"scad":   output,
+			"errors": []interface{}{},
+		}
+	}))
+
+	// Keep the Go runtime alive so that compile() remains callable
+	select {}
+}
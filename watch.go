@@ -0,0 +1,157 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type watchArgs struct {
+	globalArgs
+	Filenames []string      `arg:"positional,required" help:"JavaScript input files"`
+	OutputDir string        `arg:"--output-dir" help:"write each file's output to a .scad file in this directory instead of alongside it"`
+	Interval  time.Duration `help:"how often to check the files for changes"`
+}
+
+func (watchArgs) Description() string {
+	return ("Compiles go-scad scripts again whenever they, the data files" +
+		" they load or the plugin and init scripts change, writing each" +
+		" one's output to a .scad file alongside it.  OpenSCAD shows the" +
+		" changes if its \"Automatic Reload and Preview\" option is on.  A" +
+		" script which fails is run again when any of these files change.")
+}
+
+func watch(argv []string) {
+	args := watchArgs{Interval: 500 * time.Millisecond}
+	p := parseArgs("go-scad watch", argv, &args)
+	opts, config := args.load(p)
+	if args.OutputDir == "" {
+		args.OutputDir = config.OutputDir
+	}
+	if args.Interval <= 0 {
+		p.Fail("--interval must be positive")
+	}
+	if args.OutputDir != "" {
+		if err := os.MkdirAll(args.OutputDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	w := newWatcher(args.Filenames, args.OutputDir, opts)
+	w.pluginPaths, w.initPaths = args.scriptPaths(config)
+	for {
+		w.poll()
+		time.Sleep(args.Interval)
+	}
+}
+
+// watcher compiles a set of scripts each time poll is called, if they or
+// the files they use have changed since the last time.
+type watcher struct {
+	filenames []string
+	outputDir string
+	opts      compileOptions
+	// pluginPaths and initPaths list the plugin and init scripts, which are
+	// read again each time in case they have changed.
+	pluginPaths []string
+	initPaths   []string
+
+	// The cache tells whether a script or its data files have changed, so
+	// that scripts are only run again when they need to be.  written holds
+	// the output last written for each file, and failed holds the last
+	// error for each file which failed.
+	cache      *compileCache
+	written    map[string]string
+	failed     map[string]watchFailure
+	scriptsErr string
+}
+
+// watchFailure describes a script which failed.  It is run again once its
+// source, options (including plugin and init scripts) or data files change.
+// A zero key means that it should be tried again next time regardless.
+type watchFailure struct {
+	key       [sha256.Size]byte
+	err       string
+	dataFiles map[string][sha256.Size]byte
+}
+
+func newWatcher(filenames []string, outputDir string, opts compileOptions) *watcher {
+	return &watcher{
+		filenames: filenames,
+		outputDir: outputDir,
+		opts:      opts,
+		cache:     newCompileCache(len(filenames)),
+		written:   map[string]string{},
+		failed:    map[string]watchFailure{},
+	}
+}
+
+// poll compiles each script which has changed and writes its output,
+// logging what happened.
+func (w *watcher) poll() {
+	plugins, err := readScriptFiles(w.pluginPaths)
+	if err == nil {
+		w.opts.Init, err = readScriptFiles(w.initPaths)
+	}
+	if err != nil {
+		if err.Error() != w.scriptsErr {
+			log.Print(err)
+		}
+		w.scriptsErr = err.Error()
+		return
+	}
+	w.opts.Plugins = plugins
+	w.scriptsErr = ""
+
+	for _, filename := range w.filenames {
+		fail := func(f watchFailure) {
+			if old, ok := w.failed[filename]; !ok || old.err != f.err {
+				log.Printf("%s: %s", filename, f.err)
+			}
+			w.failed[filename] = f
+		}
+		source, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fail(watchFailure{err: err.Error()})
+			continue
+		}
+
+		fileOpts := w.opts
+		fileOpts.BaseDir = filepath.Dir(filename)
+		key := cacheKey(string(source), fileOpts)
+		if f, ok := w.failed[filename]; ok && f.key == key && !dataFilesChanged(f.dataFiles) {
+			continue
+		}
+		output, ok := w.cache.lookup(string(source), fileOpts)
+		if !ok {
+			fileOpts.DataFiles = map[string][sha256.Size]byte{}
+			output, err = w.cache.compile(string(source), fileOpts)
+			if err != nil {
+				fail(watchFailure{key, formatError(err), fileOpts.DataFiles})
+				continue
+			}
+		}
+		delete(w.failed, filename)
+		if output == w.written[filename] {
+			continue
+		}
+
+		outputFilename := filename + ".scad"
+		if w.outputDir != "" {
+			outputFilename = filepath.Join(w.outputDir, filepath.Base(filename)+".scad")
+		}
+		if err := ioutil.WriteFile(outputFilename, []byte(output), 0644); err != nil {
+			// Try again next time, even if nothing has changed
+			fail(watchFailure{err: err.Error()})
+			continue
+		}
+		w.written[filename] = output
+		log.Printf("%s: wrote %s", filename, outputFilename)
+	}
+}
@@ -0,0 +1,68 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWatchDataFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-scad-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	write := func(name, contents string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filename := filepath.Join(dir, "part.js")
+	write("part.js", "pendown(); forward(load_json('size.json').size); penup();")
+	write("size.json", `{"size": 5}`)
+	write("plugin.js", "")
+
+	w := newWatcher([]string{filename}, "", compileOptions{})
+	w.pluginPaths = []string{filepath.Join(dir, "plugin.js")}
+	// Polls once, and checks what was logged and the output file
+	check := func(step, expectedLog, expectedOutput string) {
+		logged.Reset()
+		w.poll()
+		if !strings.Contains(logged.String(), expectedLog) || (expectedLog == "" && logged.Len() > 0) {
+			t.Errorf("%s: logged %q, expected %q", step, logged.String(), expectedLog)
+		}
+		output, err := ioutil.ReadFile(filename + ".scad")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(output), expectedOutput) {
+			t.Errorf("%s: wrote\n%s\nexpected it to contain %q", step, output, expectedOutput)
+		}
+	}
+
+	check("first poll", "wrote "+filename+".scad", "[5,0.5]")
+	check("unchanged", "", "[5,0.5]")
+	write("size.json", `{"size": `)
+	check("broken data file", "part.js: JavaScript error", "[5,0.5]")
+	check("still broken", "", "[5,0.5]")
+	write("size.json", `{"size": 7}`)
+	check("fixed data file", "wrote "+filename+".scad", "[7,0.5]")
+
+	// A plugin which breaks the script is noticed too, and so is fixing it
+	write("plugin.js", "forward = null;")
+	check("broken plugin", "part.js: JavaScript error", "[7,0.5]")
+	write("plugin.js", "")
+	check("fixed plugin", "", "[7,0.5]")
+	write("size.json", `{"size": 3}`)
+	check("changed data file", "wrote "+filename+".scad", "[3,0.5]")
+}